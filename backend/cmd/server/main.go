@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
@@ -12,6 +14,7 @@ import (
 	"github.com/oauth-app/backend/internal/database"
 	"github.com/oauth-app/backend/internal/handlers"
 	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
 	"github.com/oauth-app/backend/internal/services"
 )
 
@@ -26,7 +29,20 @@ func main() {
 	runMigrations()
 
 	// Initialize auth
-	services.InitAuth()
+	if err := services.InitAuth(); err != nil {
+		log.Fatalf("Failed to initialize auth: %v", err)
+	}
+
+	// Give the admin role to ADMIN_EMAILS
+	if err := services.BootstrapAdmins(context.Background()); err != nil {
+		log.Printf("⚠️  Failed to bootstrap admins: %v", err)
+	}
+
+	// Purge accounts whose deletion grace period is over
+	services.StartAccountPurger(context.Background(), time.Hour)
+
+	// Remove data export archives past their retention
+	services.StartExportCleaner(context.Background(), time.Hour)
 
 	// Setup Gin
 	if os.Getenv("GIN_MODE") == "release" {
@@ -43,29 +59,151 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public keys for verifying tokens issued by this backend
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
+	// OpenID Connect provider for other apps
+	r.GET("/.well-known/openid-configuration", handlers.GetOpenIDConfiguration)
+	r.GET("/oauth/authorize", handlers.Authorize)
+	r.POST("/oauth/token", handlers.Token)
+	r.POST("/oauth/introspect", handlers.IntrospectToken)
+	r.POST("/oauth/revoke", handlers.RevokeToken)
+	r.POST("/oauth/device/code", handlers.DeviceAuthorization)
+	r.GET("/userinfo", handlers.UserInfo)
+	r.POST("/userinfo", handlers.UserInfo)
+
 	// Auth routes (public)
-	r.GET("/auth/google", handlers.GoogleLogin)
-	r.GET("/auth/google/callback", handlers.GoogleCallback)
+	r.GET("/auth/providers", handlers.ListProviders)
+	r.GET("/auth/:provider", handlers.ProviderLogin)
+	r.GET("/auth/:provider/callback", handlers.ProviderCallback)
 	r.POST("/auth/logout", handlers.Logout)
+	r.POST("/auth/refresh", handlers.RefreshToken)
+	r.POST("/auth/token", handlers.ExchangeLoginCode)
+	r.POST("/auth/restore", handlers.RestoreAccount)
+	r.GET("/auth/suspension", handlers.GetSuspensionNotice)
+	r.GET("/auth/invitations/:code", handlers.GetInvitationPreview)
+	r.GET("/auth/mfa", handlers.GetMFAChallenge)
+	r.POST("/auth/mfa/verify", handlers.VerifyMFA)
+	r.POST("/auth/mfa/passkey/begin", handlers.BeginMFAPasskey)
+	r.POST("/auth/mfa/passkey/finish", handlers.FinishMFAPasskey)
+	r.POST("/auth/passkey/begin", handlers.BeginPasskeyLogin)
+	r.POST("/auth/passkey/finish", handlers.FinishPasskeyLogin)
 
 	// Protected routes
 	auth := r.Group("/")
 	auth.Use(middleware.AuthMiddleware())
 	{
 		auth.GET("/auth/me", handlers.GetCurrentUser)
+		auth.POST("/auth/impersonation/stop", handlers.StopImpersonation)
+
+		// Step-up re-authentication (refreshes the session's auth_time).
+		// Routes that change the account or its credentials are closed to
+		// impersonation sessions.
+		auth.GET("/auth/reauth/:provider", middleware.BlockImpersonation(), handlers.ReauthWithProvider)
+		auth.POST("/auth/reauth/totp", middleware.BlockImpersonation(), handlers.ReauthWithCode)
+		auth.POST("/auth/reauth/passkey/begin", middleware.BlockImpersonation(), handlers.BeginReauthPasskey)
+		auth.POST("/auth/reauth/passkey/finish", middleware.BlockImpersonation(), handlers.FinishReauthPasskey)
 
 		// User routes
-		auth.GET("/api/users/me", handlers.GetUser)
-		auth.PUT("/api/users/me", handlers.UpdateUser)
-		auth.PUT("/api/users/me/username", handlers.UpdateUsername)
-		auth.PUT("/api/users/me/toggle-public", handlers.TogglePublic)
-		auth.DELETE("/api/users/me", handlers.DeleteUser)
-		auth.GET("/api/users/me/stats", handlers.GetUserStats)
-
-		// Activity routes
-		auth.GET("/api/activity", handlers.GetActivity)
+		auth.DELETE("/api/users/me", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.DeleteUser)
+
+		// Data export routes
+		auth.POST("/api/users/me/export", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.RequestDataExport)
+		auth.GET("/api/users/me/export/:id", handlers.GetDataExport)
+
+		// Linked login identities
+		auth.GET("/api/users/me/identities", handlers.GetIdentities)
+		auth.POST("/api/users/me/identities/:provider", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.LinkIdentity)
+		auth.DELETE("/api/users/me/identities/:id", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.UnlinkIdentity)
+
+		// Session routes
+		auth.GET("/api/users/me/sessions", handlers.GetSessions)
+		auth.DELETE("/api/users/me/sessions", middleware.BlockImpersonation(), handlers.RevokeAllSessions)
+		auth.DELETE("/api/users/me/sessions/:id", middleware.BlockImpersonation(), handlers.RevokeSession)
+
+		// Personal access token routes
+		auth.GET("/api/users/me/tokens", handlers.GetAccessTokens)
+		auth.POST("/api/users/me/tokens", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.CreateAccessToken)
+		auth.DELETE("/api/users/me/tokens/:id", middleware.BlockImpersonation(), handlers.RevokeAccessToken)
+
+		// Apps the user allowed through /oauth/authorize or the device flow
+		auth.GET("/api/users/me/apps", handlers.GetAuthorizedApps)
+		auth.DELETE("/api/users/me/apps/:id", middleware.BlockImpersonation(), handlers.RevokeAuthorizedApp)
+
+		// Two-factor authentication routes
+		auth.GET("/api/users/me/mfa", handlers.GetMFAStatus)
+		auth.POST("/api/users/me/mfa/totp", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.BeginTOTPEnrollment)
+		auth.POST("/api/users/me/mfa/totp/confirm", middleware.BlockImpersonation(), handlers.ConfirmTOTPEnrollment)
+		auth.DELETE("/api/users/me/mfa/totp", middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.DisableTOTP)
+		auth.POST("/api/users/me/mfa/recovery-codes", middleware.BlockImpersonation(), handlers.RegenerateRecoveryCodes)
+
+		// Passkey routes
+		auth.GET("/api/users/me/passkeys", handlers.GetPasskeys)
+		auth.POST("/api/users/me/passkeys/begin", middleware.BlockImpersonation(), middleware.RequireRecentSecondFactor(), handlers.BeginPasskeyRegistration)
+		auth.POST("/api/users/me/passkeys", middleware.BlockImpersonation(), handlers.FinishPasskeyRegistration)
+		auth.DELETE("/api/users/me/passkeys/:id", middleware.BlockImpersonation(), middleware.RequireRecentSecondFactor(), handlers.DeletePasskey)
+
+		// Consent for apps signing in through /oauth/authorize
+		auth.GET("/api/oauth/authorize", handlers.GetAuthorizationConsent)
+		auth.POST("/api/oauth/authorize", middleware.BlockImpersonation(), handlers.DecideAuthorization)
+		// Approval of devices signing in through /oauth/device/code
+		auth.GET("/api/oauth/device", handlers.GetDeviceConsent)
+		auth.POST("/api/oauth/device", middleware.BlockImpersonation(), handlers.DecideDeviceAuthorization)
 	}
 
+	// Admin routes, each guarded by the permission it needs. An impersonation
+	// session never has admin powers.
+	admin := auth.Group("/api/admin")
+	admin.Use(middleware.BlockImpersonation())
+	{
+		admin.GET("/users", middleware.RequirePermission(models.PermUsersRead), handlers.AdminListUsers)
+		admin.GET("/users/:id", middleware.RequirePermission(models.PermUsersRead), handlers.AdminGetUser)
+		admin.GET("/users/:id/activity", middleware.RequirePermission(models.PermUsersActivity), handlers.AdminGetUserActivity)
+		admin.PUT("/users/:id/role", middleware.RequirePermission(models.PermRolesManage), handlers.AdminSetUserRole)
+		admin.POST("/users/:id/suspension", middleware.RequirePermission(models.PermUsersSuspend), handlers.AdminSuspendUser)
+		admin.DELETE("/users/:id/suspension", middleware.RequirePermission(models.PermUsersSuspend), handlers.AdminLiftSuspension)
+		admin.DELETE("/users/:id", middleware.RequirePermission(models.PermUsersDelete), handlers.AdminDeleteUser)
+		admin.POST("/users/:id/impersonate", middleware.RequirePermission(models.PermUsersImpersonate), middleware.RequireRecentAuth(), handlers.AdminImpersonateUser)
+
+		admin.GET("/invitations", middleware.RequirePermission(models.PermInvitationsManage), handlers.GetInvitations)
+		admin.POST("/invitations", middleware.RequirePermission(models.PermInvitationsManage), handlers.CreateInvitation)
+		admin.DELETE("/invitations/:id", middleware.RequirePermission(models.PermInvitationsManage), handlers.RevokeInvitation)
+
+		admin.GET("/oauth-clients", middleware.RequirePermission(models.PermClientsManage), handlers.GetOAuthClients)
+		admin.POST("/oauth-clients", middleware.RequirePermission(models.PermClientsManage), handlers.CreateOAuthClient)
+		admin.DELETE("/oauth-clients/:id", middleware.RequirePermission(models.PermClientsManage), handlers.RevokeOAuthClient)
+
+		admin.GET("/scim-tenants", middleware.RequirePermission(models.PermSCIMManage), handlers.GetSCIMTenants)
+		admin.POST("/scim-tenants", middleware.RequirePermission(models.PermSCIMManage), handlers.CreateSCIMTenant)
+		admin.DELETE("/scim-tenants/:id", middleware.RequirePermission(models.PermSCIMManage), handlers.RevokeSCIMTenant)
+	}
+
+	// SCIM 2.0 user provisioning, authenticated by a tenant token
+	scim := r.Group("/scim/v2")
+	scim.Use(middleware.SCIMAuth())
+	{
+		scim.GET("/ServiceProviderConfig", handlers.GetSCIMServiceProviderConfig)
+		scim.GET("/Users", handlers.ListSCIMUsers)
+		scim.POST("/Users", handlers.CreateSCIMUser)
+		scim.GET("/Users/:id", handlers.GetSCIMUser)
+		scim.PUT("/Users/:id", handlers.ReplaceSCIMUser)
+		scim.PATCH("/Users/:id", handlers.PatchSCIMUser)
+		scim.DELETE("/Users/:id", handlers.DeleteSCIMUser)
+	}
+
+	// Protected routes also available to personal access tokens with the given scope
+	r.GET("/api/users/me", middleware.RequireScope(models.ScopeProfileRead), handlers.GetUser)
+	r.PUT("/api/users/me", middleware.RequireScope(models.ScopeProfileWrite), handlers.UpdateUser)
+	r.PUT("/api/users/me/username", middleware.RequireScope(models.ScopeProfileWrite), middleware.BlockImpersonation(), middleware.RequireRecentAuth(), handlers.UpdateUsername)
+	r.PUT("/api/users/me/toggle-public", middleware.RequireScope(models.ScopeProfileWrite), handlers.TogglePublic)
+	r.GET("/api/users/me/profile-sync", middleware.RequireScope(models.ScopeProfileRead), handlers.GetProfileSync)
+	r.PUT("/api/users/me/profile-sync", middleware.RequireScope(models.ScopeProfileWrite), handlers.UpdateProfileSync)
+	r.GET("/api/users/me/stats", middleware.RequireScope(models.ScopeProfileRead), handlers.GetUserStats)
+	r.GET("/api/activity", middleware.RequireScope(models.ScopeActivityRead), handlers.GetActivity)
+
+	// Signed data export download link
+	r.GET("/api/exports/:id/download", handlers.DownloadDataExport)
+
 	// Public profile route
 	r.GET("/api/profile/:username", handlers.GetPublicProfile)
 
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/users/me/tokens
+func GetAccessTokens(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	tokens, err := services.GetAccessTokens(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tokens"})
+		return
+	}
+
+	if tokens == nil {
+		tokens = []models.PersonalAccessToken{}
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// POST /api/users/me/tokens — The secret is only returned in this response
+func CreateAccessToken(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and at least one scope are required"})
+		return
+	}
+
+	token, secret, err := services.CreateAccessToken(context.Background(), userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_scopes": models.AccessTokenScopes})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Created access token %q", token.Name))
+
+	c.JSON(http.StatusCreated, gin.H{"token": token, "secret": secret})
+}
+
+// DELETE /api/users/me/tokens/:id
+func RevokeAccessToken(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	if err := services.RevokeAccessToken(context.Background(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke token"})
+		return
+	}
+
+	logActivity(c, userID, "Revoked an access token")
+
+	c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+}
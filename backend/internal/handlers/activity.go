@@ -26,3 +26,9 @@ func GetActivity(c *gin.Context) {
 
 	c.JSON(http.StatusOK, activities)
 }
+
+// logActivity records an action on userID's account, attributed to the
+// administrator when the request comes from an impersonation session.
+func logActivity(c *gin.Context, userID, action string) {
+	_ = services.LogActivityBy(context.Background(), userID, c.GetString("impersonatorID"), action)
+}
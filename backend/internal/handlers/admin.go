@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/admin/users?q=&role=&limit=&offset= — Search users by name,
+// email or username
+func AdminListUsers(c *gin.Context) {
+	limit := queryInt(c, "limit", 50, 200)
+	offset := queryInt(c, "offset", 0, -1)
+
+	users, total, err := services.SearchUsers(context.Background(), c.Query("q"), c.Query("role"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+		return
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total})
+}
+
+// GET /api/admin/users/:id — User with linked identities and suspension
+func AdminGetUser(c *gin.Context) {
+	ctx := context.Background()
+
+	user, err := services.FindUserByID(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	identities, err := services.GetUserIdentities(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch identities"})
+		return
+	}
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+
+	suspension, err := services.GetActiveSuspension(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch suspension"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user, "identities": identities, "suspension": suspension})
+}
+
+// GET /api/admin/users/:id/activity?limit=
+func AdminGetUserActivity(c *gin.Context) {
+	activities, err := services.GetRecentActivity(context.Background(), c.Param("id"), queryInt(c, "limit", 100, 500))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch activity"})
+		return
+	}
+
+	if activities == nil {
+		activities = []models.ActivityLog{}
+	}
+
+	c.JSON(http.StatusOK, activities)
+}
+
+// PUT /api/admin/users/:id/role
+func AdminSetUserRole(c *gin.Context) {
+	actorID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required", "roles": models.Roles})
+		return
+	}
+
+	target, ok := findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.SetUserRole(ctx, actorID, target.ID, req.Role); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "roles": models.Roles})
+		case errors.Is(err, services.ErrCannotChangeOwnRole):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change role"})
+		}
+		return
+	}
+
+	_ = services.LogActivity(ctx, target.ID, fmt.Sprintf("Role changed to %s", req.Role))
+	_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Changed role of %s to %s", target.Email, req.Role))
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated", "role": req.Role})
+}
+
+// POST /api/admin/users/:id/suspension — Suspends or bans the user and
+// signs them out everywhere
+func AdminSuspendUser(c *gin.Context) {
+	actorID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	var req models.SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	target, ok := findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	suspension, err := services.SuspendUser(ctx, actorID, target.ID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrAlreadySuspended) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to suspend user"})
+		return
+	}
+
+	switch {
+	case suspension.Kind == models.SuspensionKindBan:
+		_ = services.LogActivity(ctx, target.ID, fmt.Sprintf("Account banned: %s", req.Reason))
+		_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Banned %s", target.Email))
+	case suspension.ExpiresAt != nil:
+		until := suspension.ExpiresAt.UTC().Format(time.RFC3339)
+		_ = services.LogActivity(ctx, target.ID, fmt.Sprintf("Account suspended until %s: %s", until, req.Reason))
+		_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Suspended %s until %s", target.Email, until))
+	default:
+		_ = services.LogActivity(ctx, target.ID, fmt.Sprintf("Account suspended: %s", req.Reason))
+		_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Suspended %s", target.Email))
+	}
+
+	c.JSON(http.StatusCreated, suspension)
+}
+
+// DELETE /api/admin/users/:id/suspension — Lifts the active suspension
+func AdminLiftSuspension(c *gin.Context) {
+	actorID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	target, ok := findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.LiftSuspension(ctx, actorID, target.ID); err != nil {
+		if errors.Is(err, services.ErrNotSuspended) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to lift suspension"})
+		return
+	}
+
+	_ = services.LogActivity(ctx, target.ID, "Account suspension lifted")
+	_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Lifted suspension of %s", target.Email))
+
+	c.JSON(http.StatusOK, gin.H{"message": "suspension lifted"})
+}
+
+// DELETE /api/admin/users/:id — Schedules the account for deletion like
+// DELETE /api/users/me; it stays restorable during the grace period
+func AdminDeleteUser(c *gin.Context) {
+	actorID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	target, ok := findManagedUser(c)
+	if !ok {
+		return
+	}
+	if target.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "account is already pending deletion"})
+		return
+	}
+
+	purgeAfter, err := services.SoftDeleteUser(ctx, target.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
+		return
+	}
+
+	_ = services.LogActivity(ctx, target.ID, "Account deleted by an administrator")
+	_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Deleted account %s", target.Email))
+
+	c.JSON(http.StatusOK, gin.H{"message": "account scheduled for deletion", "purge_after": purgeAfter})
+}
+
+// findManagedUser loads the :id user and checks that the caller may act on
+// them; it writes the error response otherwise.
+func findManagedUser(c *gin.Context) (*models.User, bool) {
+	actorID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	target, err := services.FindUserByID(context.Background(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	if target.ID == actorID {
+		c.JSON(http.StatusConflict, gin.H{"error": "use your own account settings instead"})
+		return nil, false
+	}
+	if !services.CanManage(c.GetString("role"), target.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrNotAllowedOnTarget.Error()})
+		return nil, false
+	}
+	return target, true
+}
+
+// queryInt reads a non-negative integer query parameter, capped at max
+// (max < 0 means no cap).
+func queryInt(c *gin.Context, key string, def, max int) int {
+	n, err := strconv.Atoi(c.Query(key))
+	if err != nil || n < 0 {
+		return def
+	}
+	if max >= 0 && n > max {
+		return max
+	}
+	return n
+}
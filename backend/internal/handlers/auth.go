@@ -2,116 +2,399 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
 	"github.com/oauth-app/backend/internal/services"
 	"golang.org/x/oauth2"
 )
 
-type GoogleUserInfo struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Picture string `json:"picture"`
+// GET /auth/providers — Lists the enabled login providers
+func ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": providers.Names()})
 }
 
-// GET /auth/google — Redirects to Google consent screen
-func GoogleLogin(c *gin.Context) {
-	url := services.GoogleOAuthConfig.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+// GET /auth/:provider — Redirects to the provider's consent screen
+func ProviderLogin(c *gin.Context) {
+	provider, ok := providers.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return
+	}
+
+	state, err := services.NewOAuthState(provider.Name())
+	if err != nil {
+		log.Printf("Failed to create OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+
+	// Native / CLI apps receive a one-time code at their own redirect URI
+	// instead of cookies, and later redeem it with their PKCE verifier.
+	if appRedirect := c.Query("redirect_uri"); appRedirect != "" {
+		if !services.IsAllowedAppRedirect(appRedirect) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_uri not allowed"})
+			return
+		}
+		if c.Query("code_challenge") == "" || c.Query("code_challenge_method") != "S256" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code_challenge with code_challenge_method=S256 is required"})
+			return
+		}
+		state.AppRedirectURI = appRedirect
+		state.AppCodeChallenge = c.Query("code_challenge")
+	}
+
+	// Invitation links carry ?invite=; the signed state keeps the checked
+	// invitation until the callback decides whether this is a sign-up
+	if code := c.Query("invite"); code != "" {
+		invitation, err := services.LookupInvitation(context.Background(), code)
+		if err != nil {
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(frontendBaseURL(), "error", "invalid_invitation"))
+			return
+		}
+		state.InvitationID = invitation.ID
+	}
+
+	cookie, err := services.SignOAuthState(state)
+	if err != nil {
+		log.Printf("Failed to sign OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+
+	// Bind state + PKCE verifier to this browser for the callback
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OAuthStateCookie, cookie, int(services.OAuthStateTTL.Seconds()), "/auth", "", false, true)
+
+	url := provider.AuthCodeURL(state.State,
+		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", state.Nonce))
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-// GET /auth/google/callback — Handles OAuth callback
-func GoogleCallback(c *gin.Context) {
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:5173"
+// GET /auth/:provider/callback — Handles OAuth callback
+func ProviderCallback(c *gin.Context) {
+	frontendURL := frontendBaseURL()
+
+	provider, ok := providers.Get(c.Param("provider"))
+	if !ok {
+		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=unknown_provider")
+		return
+	}
+
+	// Verify state against the signed cookie, then discard it (single use)
+	stateCookie, _ := c.Cookie(services.OAuthStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OAuthStateCookie, "", -1, "/auth", "", false, true)
+
+	state, err := services.VerifyOAuthState(provider.Name(), stateCookie, c.Query("state"))
+	if err != nil {
+		log.Printf("OAuth state verification failed: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=state_mismatch")
+		return
+	}
+
+	// From here on errors go back to whoever started the login
+	returnURL := frontendURL
+	if state.AppRedirectURI != "" {
+		returnURL = state.AppRedirectURI
 	}
 
 	code := c.Query("code")
 	if code == "" {
-		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=no_code")
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "no_code"))
 		return
 	}
 
-	token, err := services.GoogleOAuthConfig.Exchange(context.Background(), code)
+	token, err := provider.Exchange(context.Background(), code,
+		oauth2.VerifierOption(state.CodeVerifier))
 	if err != nil {
 		log.Printf("OAuth exchange error: %v", err)
-		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=exchange_failed")
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "exchange_failed"))
 		return
 	}
 
-	// Fetch user info from Google
-	client := services.GoogleOAuthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	// Verify the ID token (OIDC providers) and read the normalized profile
+	profile, err := provider.Profile(context.Background(), token, state.Nonce)
+	if err == nil && profile.Subject == "" {
+		// Identities are keyed by subject: an empty one would be shared by everyone
+		err = fmt.Errorf("%s: profile has no subject", provider.Name())
+	}
 	if err != nil {
 		log.Printf("Failed to get user info: %v", err)
-		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=userinfo_failed")
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "userinfo_failed"))
 		return
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	var googleUser GoogleUserInfo
-	if err := json.Unmarshal(body, &googleUser); err != nil {
-		log.Printf("Failed to parse user info: %v", err)
-		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=parse_failed")
+	// Step-up re-authentication of an existing session, not a login
+	if state.ReauthSessionID != "" {
+		completeProviderReauth(c, state, profile)
+		return
+	}
+
+	// Attaching another identity to a signed-in user, not a login
+	if state.LinkUserID != "" {
+		completeIdentityLink(c, state, profile)
 		return
 	}
 
 	ctx := context.Background()
 
-	// Find or create user (always fresh — no soft delete/restore)
-	user, err := services.FindUserByGoogleID(ctx, googleUser.ID)
+	// Find or create user
+	user, err := services.FindUserByIdentity(ctx, profile.Provider, profile.Subject)
 	if err != nil {
-		// New user — create fresh account
-		user, err = services.CreateUser(ctx, googleUser.ID, googleUser.Name, googleUser.Email, googleUser.Picture)
+		// Accounts provisioned through SCIM are claimed on first login
+		user, err = services.ClaimProvisionedUser(ctx, profile)
+		if err == nil {
+			_ = services.LogActivity(ctx, user.ID, fmt.Sprintf("Linked %s identity on first login", profile.Provider))
+		}
+	}
+	if err == nil && user.DeletedAt != nil {
+		// Pending deletion: no login, but the browser may restore the account
+		setRestoreCookie(c, user.ID)
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "pending_deletion"))
+		return
+	}
+	if err == nil && isSuspended(ctx, user.ID) {
+		setSuspensionNoticeCookie(c, user.ID)
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "account_suspended"))
+		return
+	}
+	if err != nil {
+		// New user — create fresh account if the admission policy allows it
+		user, err = services.SignUp(ctx, profile, state.InvitationID)
+		switch {
+		case errors.Is(err, services.ErrSignupNotAllowed):
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "signup_not_allowed"))
+			return
+		case errors.Is(err, services.ErrInvalidInvitation):
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "invalid_invitation"))
+			return
+		case errors.Is(err, services.ErrEmailInUse):
+			// Never merge accounts by email; the owner can link this identity
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "email_in_use"))
+			return
+		}
 		if err != nil {
 			log.Printf("Failed to create user: %v", err)
-			c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=create_failed")
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "create_failed"))
 			return
 		}
 		_ = services.LogActivity(ctx, user.ID, "Account created")
+		if user.InvitedBy != nil {
+			_ = services.LogActivity(ctx, *user.InvitedBy, fmt.Sprintf("%s accepted your invitation", user.Email))
+		}
 	} else {
 		// Existing user — increment login
 		if err := services.IncrementLoginCount(ctx, user.ID); err != nil {
 			log.Printf("Failed to increment login count: %v", err)
 		}
+		_ = services.TouchIdentity(ctx, profile.Provider, profile.Subject, profile.Email)
+		syncProfile(ctx, user, profile)
 		user, _ = services.FindUserByID(ctx, user.ID)
 	}
 
 	// Log login activity
-	_ = services.LogActivity(ctx, user.ID, "Logged in")
+	_ = services.LogActivity(ctx, user.ID, fmt.Sprintf("Logged in with %s", profile.Provider))
+
+	// Accounts with two-factor authentication get a partial session that
+	// must be upgraded with a code at POST /auth/mfa/verify
+	mfaEnabled, err := services.IsMFAEnabled(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to check MFA status: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "session_failed"))
+		return
+	}
+	if mfaEnabled {
+		session, err := services.CreateSession(ctx, user.ID, c.ClientIP(), c.Request.UserAgent(), true)
+		if err != nil {
+			log.Printf("Failed to create session: %v", err)
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "session_failed"))
+			return
+		}
+		mfaToken, err := services.GenerateMFAToken(user.ID, session.ID, state.AppRedirectURI, state.AppCodeChallenge)
+		if err != nil {
+			log.Printf("Failed to generate MFA token: %v", err)
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "jwt_failed"))
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(services.MFATokenCookie, mfaToken, int(services.MFATokenTTL.Seconds()), "/auth/mfa", "", false, true)
+		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/mfa")
+		return
+	}
+
+	// Native apps get a one-time code; the session is created when it is redeemed
+	if state.AppRedirectURI != "" {
+		loginCode, err := services.CreateLoginCode(ctx, user.ID, state.AppCodeChallenge, state.AppRedirectURI)
+		if err != nil {
+			log.Printf("Failed to create login code: %v", err)
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "session_failed"))
+			return
+		}
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(state.AppRedirectURI, "code", loginCode))
+		return
+	}
 
-	// Generate JWT
-	jwtToken, err := services.GenerateJWT(user.ID, user.Email, user.Username)
+	// Start a server-side session and issue tokens bound to it
+	session, err := services.CreateSession(ctx, user.ID, c.ClientIP(), c.Request.UserAgent(), false)
+	if err != nil {
+		log.Printf("Failed to create session: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "session_failed"))
+		return
+	}
+
+	accessToken, refreshToken, err := services.IssueTokens(ctx, user, session.ID)
 	if err != nil {
 		log.Printf("Failed to generate JWT: %v", err)
-		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"?error=jwt_failed")
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "jwt_failed"))
 		return
 	}
 
-	// Set HTTP-only cookie
-	secure := false // Set to true in production with HTTPS
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("token", jwtToken, 7*24*3600, "/", "", secure, true)
+	// Set HTTP-only cookies
+	middleware.SetAuthCookies(c, accessToken, refreshToken)
 
 	c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/dashboard")
 }
 
-// POST /auth/logout
+// isSuspended reports whether the user may not sign in. A failed lookup
+// counts as suspended.
+func isSuspended(ctx context.Context, userID string) bool {
+	suspension, err := services.GetActiveSuspension(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to check suspension: %v", err)
+		return true
+	}
+	return suspension != nil
+}
+
+// syncProfile refreshes the profile from the provider per the user's sync
+// policies and records each change in the activity log.
+func syncProfile(ctx context.Context, user *models.User, profile *providers.Profile) {
+	changes, err := services.SyncProfile(ctx, user, profile)
+	if err != nil {
+		log.Printf("Failed to sync profile: %v", err)
+	}
+	for _, change := range changes {
+		action := fmt.Sprintf("Synced %s from %s", change.Field, profile.Provider)
+		if change.Field != models.ProfileFieldImage {
+			action += fmt.Sprintf(": %q → %q", change.OldValue, change.NewValue)
+		}
+		_ = services.LogActivity(ctx, user.ID, action)
+	}
+}
+
+// POST /auth/token — Redeems a native app login code for tokens
+func ExchangeLoginCode(c *gin.Context) {
+	var req models.LoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code, code_verifier and redirect_uri are required"})
+		return
+	}
+
+	ctx := context.Background()
+	userID, err := services.RedeemLoginCode(ctx, req.Code, req.CodeVerifier, req.RedirectURI)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired code"})
+		return
+	}
+
+	user, err := services.FindUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// The account may have been deleted or suspended since the code was issued
+	if user.DeletedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "pending_deletion", "purge_after": user.PurgeAfter})
+		return
+	}
+	if isSuspended(ctx, user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "account_suspended"})
+		return
+	}
+
+	session, err := services.CreateSession(ctx, user.ID, c.ClientIP(), c.Request.UserAgent(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+
+	accessToken, refreshToken, err := services.IssueTokens(ctx, user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(accessToken, refreshToken))
+}
+
+// POST /auth/logout — Revokes the current session and clears the cookies
 func Logout(c *gin.Context) {
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("token", "", -1, "/", "", false, true)
+	if tokenString, ok := middleware.BearerToken(c); ok {
+		if claims, err := services.ValidateJWT(tokenString); err == nil && claims.ID != "" {
+			_ = services.RevokeSession(context.Background(), claims.UserID, claims.ID)
+		}
+	}
+	if tokenString, err := c.Cookie(middleware.AccessTokenCookie); err == nil && tokenString != "" {
+		if claims, err := services.ValidateJWT(tokenString); err == nil && claims.ID != "" {
+			_ = services.RevokeSession(context.Background(), claims.UserID, claims.ID)
+		}
+	}
+	// The access token may already have expired; the refresh token still identifies the session
+	if refreshToken, err := c.Cookie(middleware.RefreshTokenCookie); err == nil && refreshToken != "" {
+		_ = services.RevokeSessionByRefreshToken(context.Background(), refreshToken)
+	}
+
+	middleware.ClearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
+// POST /auth/refresh — Rotates the refresh token and issues a new access token.
+// Browser clients use the refresh cookie; API clients send {"refresh_token"}
+// and get the new tokens in the response body.
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	_ = c.ShouldBindJSON(&req)
+
+	refreshToken := req.RefreshToken
+	fromBody := refreshToken != ""
+	if !fromBody {
+		refreshToken, _ = c.Cookie(middleware.RefreshTokenCookie)
+	}
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing refresh token"})
+		return
+	}
+
+	accessToken, newRefreshToken, err := services.RefreshTokens(context.Background(), refreshToken)
+	if err != nil {
+		if !fromBody {
+			middleware.ClearAuthCookies(c)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		return
+	}
+
+	if fromBody {
+		c.JSON(http.StatusOK, newTokenResponse(accessToken, newRefreshToken))
+		return
+	}
+
+	middleware.SetAuthCookies(c, accessToken, newRefreshToken)
+	c.JSON(http.StatusOK, gin.H{"message": "token refreshed"})
+}
+
 // GET /auth/me — Returns current user info
 func GetCurrentUser(c *gin.Context) {
 	userID, exists := c.Get("userID")
@@ -126,5 +409,41 @@ func GetCurrentUser(c *gin.Context) {
 		return
 	}
 
+	// Lets the frontend show an impersonation banner
+	if impersonatorID := c.GetString("impersonatorID"); impersonatorID != "" {
+		user.ImpersonatedBy, _ = services.GetImpersonator(context.Background(), impersonatorID)
+	}
+
 	c.JSON(http.StatusOK, user)
 }
+
+// newTokenResponse builds the JSON body returned to bearer-token clients.
+// An empty refresh token is omitted: the client keeps its current one.
+func newTokenResponse(accessToken, refreshToken string) models.TokenResponse {
+	return models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(services.AccessTokenTTL.Seconds()),
+	}
+}
+
+// frontendBaseURL is where browser logins end up.
+func frontendBaseURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:5173"
+}
+
+// withQuery adds a query parameter to a redirect target.
+func withQuery(target, key, value string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/users/me/apps — Apps the user has allowed to access their account
+func GetAuthorizedApps(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	apps, err := services.GetAuthorizedApps(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch apps"})
+		return
+	}
+
+	if apps == nil {
+		apps = []models.AuthorizedApp{}
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+// DELETE /api/users/me/apps/:id — Revoke an app's access and all its tokens
+func RevokeAuthorizedApp(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	name, err := services.RevokeAuthorizedApp(ctx, userID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrAuthorizedAppNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke app"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Revoked access for %s", name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "app access revoked"})
+}
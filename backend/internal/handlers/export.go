@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// POST /api/users/me/export — Queues a ZIP archive of everything stored
+// about the user; poll the returned export until it is ready
+func RequestDataExport(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	export, err := services.RequestDataExport(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start data export"})
+		return
+	}
+
+	logActivity(c, userID, "Requested a data export")
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// GET /api/users/me/export/:id — Export status; includes a signed
+// download_url once the archive is ready
+func GetDataExport(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	export, err := services.GetDataExport(context.Background(), userID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch data export"})
+		return
+	}
+
+	if export.Status == models.ExportStatusReady && time.Now().Before(export.ExpiresAt) {
+		export.DownloadURL = exportDownloadURL(export.ID)
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// GET /api/exports/:id/download — Public; authorized by the link signature
+func DownloadDataExport(c *gin.Context) {
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+
+	export, err := services.GetExportForDownload(context.Background(), c.Param("id"), expires, c.Query("sig"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidExportLink):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrExportNotFound), errors.Is(err, services.ErrExportNotReady):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch data export"})
+		}
+		return
+	}
+
+	if _, err := os.Stat(export.FilePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrExportNotFound.Error()})
+		return
+	}
+
+	logActivity(c, export.UserID, "Downloaded a data export")
+
+	filename := fmt.Sprintf("data-export-%s.zip", export.CreatedAt.Format("2006-01-02"))
+	c.FileAttachment(export.FilePath, filename)
+}
+
+// exportDownloadURL signs a download link valid for services.ExportLinkTTL.
+func exportDownloadURL(exportID string) string {
+	expires := time.Now().Add(services.ExportLinkTTL).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", services.SignExportLink(exportID, expires))
+	return os.Getenv("BACKEND_URL") + "/api/exports/" + exportID + "/download?" + q.Encode()
+}
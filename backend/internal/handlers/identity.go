@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
+	"github.com/oauth-app/backend/internal/services"
+	"golang.org/x/oauth2"
+)
+
+// GET /api/users/me/identities — Login identities linked to the account
+func GetIdentities(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	identities, err := services.GetUserIdentities(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch identities"})
+		return
+	}
+
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// POST /api/users/me/identities/:provider — Starts linking another account.
+// Responds with the provider URL the browser should navigate to; the
+// callback attaches the identity and returns to ?return_to with ?linked=
+func LinkIdentity(c *gin.Context) {
+	provider, ok := providers.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return
+	}
+
+	state, err := services.NewOAuthState(provider.Name())
+	if err != nil {
+		log.Printf("Failed to create OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start linking"})
+		return
+	}
+	state.LinkUserID = fmt.Sprintf("%v", c.MustGet("userID"))
+	state.ReturnTo = services.SafeReturnPath(c.Query("return_to"))
+
+	cookie, err := services.SignOAuthState(state)
+	if err != nil {
+		log.Printf("Failed to sign OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start linking"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OAuthStateCookie, cookie, int(services.OAuthStateTTL.Seconds()), "/auth", "", false, true)
+
+	// Ask for the account picker so a second account at the same provider
+	// can be chosen
+	url := provider.AuthCodeURL(state.State,
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", state.Nonce),
+		oauth2.SetAuthURLParam("prompt", "select_account"))
+	c.JSON(http.StatusOK, gin.H{"redirect": url})
+}
+
+// completeIdentityLink finishes LinkIdentity from ProviderCallback.
+func completeIdentityLink(c *gin.Context, state *services.OAuthState, profile *providers.Profile) {
+	ctx := context.Background()
+	returnURL := frontendBaseURL() + state.ReturnTo
+
+	identity, err := services.LinkIdentity(ctx, state.LinkUserID, profile.Provider, profile.Subject, profile.Email)
+	if err != nil {
+		if errors.Is(err, services.ErrIdentityInUse) {
+			c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "identity_in_use"))
+			return
+		}
+		log.Printf("Failed to link identity: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "link_failed"))
+		return
+	}
+
+	logActivity(c, state.LinkUserID, fmt.Sprintf("Linked %s account %s", identity.Provider, identity.Email))
+
+	c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "linked", identity.Provider))
+}
+
+// DELETE /api/users/me/identities/:id — The last identity cannot be removed
+func UnlinkIdentity(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	identity, err := services.UnlinkIdentity(ctx, userID, c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrIdentityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrLastIdentity):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink identity"})
+		}
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Unlinked %s account %s", identity.Provider, identity.Email))
+
+	c.JSON(http.StatusOK, gin.H{"message": "identity unlinked"})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// POST /api/admin/users/:id/impersonate — Signs the caller in as the user.
+// The browser keeps its refresh cookie, so ending the impersonation (or
+// letting it expire) returns it to the administrator's own session.
+func AdminImpersonateUser(c *gin.Context) {
+	actorID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	target, ok := findManagedUser(c)
+	if !ok {
+		return
+	}
+	if target.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "account is pending deletion"})
+		return
+	}
+	if isSuspended(ctx, target.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "account is suspended"})
+		return
+	}
+
+	actor, err := services.FindUserByID(ctx, actorID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	accessToken, session, err := services.StartImpersonation(ctx, actor, target, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start impersonation"})
+		return
+	}
+
+	_ = services.LogActivity(ctx, target.ID, fmt.Sprintf("Administrator %s signed in as you", actor.Email))
+	_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Started impersonating %s", target.Email))
+
+	middleware.SetAuthCookies(c, accessToken, "")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(services.ImpersonationTTL.Seconds()),
+		"expires_at":   session.ExpiresAt,
+		"user":         target,
+	})
+}
+
+// POST /auth/impersonation/stop — Ends the current impersonation session
+func StopImpersonation(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	actorID, err := services.EndImpersonation(ctx, userID, c.GetString("sessionID"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotImpersonating) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end impersonation"})
+		return
+	}
+
+	if actor, err := services.FindUserByID(ctx, actorID); err == nil {
+		_ = services.LogActivity(ctx, userID, fmt.Sprintf("Administrator %s signed out of your account", actor.Email))
+	}
+	if user, err := services.FindUserByID(ctx, userID); err == nil {
+		_ = services.LogActivity(ctx, actorID, fmt.Sprintf("Stopped impersonating %s", user.Email))
+	}
+
+	middleware.ClearAccessCookie(c)
+	c.JSON(http.StatusOK, gin.H{"message": "impersonation ended"})
+}
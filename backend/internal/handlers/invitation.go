@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/admin/invitations
+func GetInvitations(c *gin.Context) {
+	invitations, err := services.GetInvitations(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch invitations"})
+		return
+	}
+
+	if invitations == nil {
+		invitations = []models.Invitation{}
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// POST /api/admin/invitations — The code and link are only returned in this response
+func CreateInvitation(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	invitation, code, err := services.CreateInvitation(context.Background(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invitation"})
+		return
+	}
+
+	action := fmt.Sprintf("Created an invitation link (%d uses)", invitation.MaxUses)
+	if invitation.Email != "" {
+		action = fmt.Sprintf("Invited %s", invitation.Email)
+	}
+	logActivity(c, userID, action)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"invitation": invitation,
+		"code":       code,
+		"url":        withQuery(frontendBaseURL()+"/", "invite", code),
+	})
+}
+
+// DELETE /api/admin/invitations/:id
+func RevokeInvitation(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	if err := services.RevokeInvitation(context.Background(), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrInvitationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke invitation"})
+		return
+	}
+
+	logActivity(c, userID, "Revoked an invitation")
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitation revoked"})
+}
+
+// GET /auth/invitations/:code — Lets the landing page greet an invitee
+// before they pick a provider
+func GetInvitationPreview(c *gin.Context) {
+	preview, err := services.GetInvitationPreview(context.Background(), c.Param("code"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidInvitation) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
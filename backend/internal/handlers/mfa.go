@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /auth/mfa — Describes the pending second-factor challenge
+func GetMFAChallenge(c *gin.Context) {
+	tokenString, _ := c.Cookie(services.MFATokenCookie)
+	claims, err := services.ValidateMFAToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no pending two-factor login"})
+		return
+	}
+
+	methods, err := services.GetMFAMethods(context.Background(), claims.Subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch two-factor methods"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"methods": methods})
+}
+
+// POST /auth/mfa/verify — Upgrades the mfa_pending session with a TOTP or
+// recovery code. Responds with where the browser should go next.
+func VerifyMFA(c *gin.Context) {
+	tokenString, _ := c.Cookie(services.MFATokenCookie)
+	claims, err := services.ValidateMFAToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no pending two-factor login"})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	ctx := context.Background()
+	userID := claims.Subject
+
+	method, err := services.CompleteMFA(ctx, userID, claims.ID, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+		abortMFALogin(c, err)
+		return
+	}
+
+	if method == services.MFAMethodRecoveryCode {
+		logActivity(c, userID, "Used a recovery code to sign in")
+	}
+	finishMFALogin(c, claims)
+}
+
+// POST /auth/mfa/passkey/begin — Starts a passkey assertion for the pending login
+func BeginMFAPasskey(c *gin.Context) {
+	tokenString, _ := c.Cookie(services.MFATokenCookie)
+	claims, err := services.ValidateMFAToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no pending two-factor login"})
+		return
+	}
+
+	assertion, challengeID, err := services.BeginPasskeyLogin(context.Background(), claims.Subject)
+	if err != nil {
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no passkeys registered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start passkey verification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenge_id": challengeID, "options": assertion})
+}
+
+// POST /auth/mfa/passkey/finish — Upgrades the mfa_pending session with a passkey
+func FinishMFAPasskey(c *gin.Context) {
+	tokenString, _ := c.Cookie(services.MFATokenCookie)
+	claims, err := services.ValidateMFAToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no pending two-factor login"})
+		return
+	}
+
+	var req models.PasskeyCeremonyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_id and credential are required"})
+		return
+	}
+
+	err = services.CompleteMFAWithPasskey(context.Background(), claims.Subject, claims.ID, req.ChallengeID, req.Credential)
+	if err != nil {
+		if isPasskeyRejection(err) {
+			log.Printf("Passkey verification failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey verification failed"})
+			return
+		}
+		abortMFALogin(c, err)
+		return
+	}
+
+	finishMFALogin(c, claims)
+}
+
+// abortMFALogin answers a failed second-factor check that ends the pending login.
+func abortMFALogin(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrTooManyMFAAttempts) || errors.Is(err, services.ErrSessionNotFound) {
+		clearMFACookie(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login expired, please sign in again"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify second factor"})
+}
+
+// finishMFALogin completes a login whose second factor was accepted and
+// responds with where the browser should go next.
+func finishMFALogin(c *gin.Context, claims *services.MFAClaims) {
+	ctx := context.Background()
+	userID := claims.Subject
+	clearMFACookie(c)
+
+	// Native app login: hand over a one-time code, the app gets its own session
+	if claims.AppRedirectURI != "" {
+		_ = services.DiscardSession(ctx, claims.ID)
+		loginCode, err := services.CreateLoginCode(ctx, userID, claims.AppCodeChallenge, claims.AppRedirectURI)
+		if err != nil {
+			log.Printf("Failed to create login code: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to complete login"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"redirect": withQuery(claims.AppRedirectURI, "code", loginCode)})
+		return
+	}
+
+	user, err := services.FindUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	accessToken, refreshToken, err := services.IssueTokens(ctx, user, claims.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+
+	middleware.SetAuthCookies(c, accessToken, refreshToken)
+	c.JSON(http.StatusOK, gin.H{"redirect": frontendBaseURL() + "/dashboard"})
+}
+
+// GET /api/users/me/mfa
+func GetMFAStatus(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	status, err := services.GetMFAStatus(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// POST /api/users/me/mfa/totp — Starts enrollment and returns the secret
+// and otpauth:// provisioning URI for the QR code
+func BeginTOTPEnrollment(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	user, err := services.FindUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	enrollment, err := services.BeginTOTPEnrollment(ctx, userID, user.Email)
+	if err != nil {
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// POST /api/users/me/mfa/totp/confirm — Recovery codes are only returned here
+func ConfirmTOTPEnrollment(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	codes, err := services.ConfirmTOTPEnrollment(context.Background(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		case errors.Is(err, services.ErrMFAEnrollmentNotStarted):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+		}
+		return
+	}
+
+	logActivity(c, userID, "Enabled two-factor authentication")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DELETE /api/users/me/mfa/totp — Requires a current TOTP or recovery code
+func DisableTOTP(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	if !verifySecondFactor(c, userID) {
+		return
+	}
+
+	if err := services.DisableTOTP(ctx, userID); err != nil {
+		if errors.Is(err, services.ErrMFANotEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+
+	logActivity(c, userID, "Disabled two-factor authentication")
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// POST /api/users/me/mfa/recovery-codes — Replaces all recovery codes
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	if !verifySecondFactor(c, userID) {
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes"})
+		return
+	}
+
+	logActivity(c, userID, "Regenerated recovery codes")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// verifySecondFactor checks the code in the request body and writes the
+// error response when it is not accepted.
+func verifySecondFactor(c *gin.Context, userID string) bool {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return false
+	}
+
+	ctx := context.Background()
+	method, err := services.VerifySecondFactor(ctx, userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			logActivity(c, userID, "Entered an invalid verification code")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		case errors.Is(err, services.ErrTooManyMFAAttempts):
+			log.Printf("Verification codes locked for user %s after repeated failures", userID)
+			logActivity(c, userID, "Verification codes locked after too many failed attempts")
+			c.Header("Retry-After", strconv.Itoa(int(services.MFALockout.Seconds())))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMFANotEnabled):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		}
+		return false
+	}
+
+	if method == services.MFAMethodRecoveryCode {
+		logActivity(c, userID, "Used a recovery code")
+	}
+	return true
+}
+
+func clearMFACookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.MFATokenCookie, "", -1, "/auth/mfa", "", false, true)
+}
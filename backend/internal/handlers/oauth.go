@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /.well-known/openid-configuration — OpenID Provider metadata
+func GetOpenIDConfiguration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, services.ProviderMetadata())
+}
+
+// GET /oauth/authorize — Start of an OpenID Connect login by another app.
+// Valid requests are handed to the frontend consent page, which uses the
+// /api/oauth/authorize endpoints below.
+func Authorize(c *gin.Context) {
+	var req models.AuthorizationRequest
+	_ = c.ShouldBindQuery(&req)
+
+	_, _, err := services.ValidateAuthorizationRequest(context.Background(), req)
+	switch {
+	case errors.Is(err, services.ErrOAuthClientNotFound), errors.Is(err, services.ErrInvalidRedirectURI):
+		// Never redirect to an unverified URI
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+	case err != nil:
+		c.Redirect(http.StatusFound, authorizationRedirect(req, url.Values{
+			"error":             {oauthErrorCode(err)},
+			"error_description": {err.Error()},
+		}))
+	default:
+		c.Redirect(http.StatusFound, frontendBaseURL()+"/oauth/consent?"+c.Request.URL.RawQuery)
+	}
+}
+
+// GET /api/oauth/authorize — Consent screen data for an authorization
+// request (same query parameters as /oauth/authorize)
+func GetAuthorizationConsent(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	var req models.AuthorizationRequest
+	_ = c.ShouldBindQuery(&req)
+
+	client, scopes, err := services.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErrorCode(err), "error_description": err.Error()})
+		return
+	}
+
+	screen, err := services.GetConsentScreen(ctx, userID, client, scopes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, screen)
+}
+
+// POST /api/oauth/authorize — Records the user's decision and returns
+// where to send the browser: back to the app with a code, or with
+// error=access_denied
+func DecideAuthorization(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	var decision models.AuthorizationDecision
+	if err := c.ShouldBindJSON(&decision); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	req := decision.AuthorizationRequest
+
+	client, scopes, err := services.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErrorCode(err), "error_description": err.Error()})
+		return
+	}
+
+	if !decision.Approved {
+		c.JSON(http.StatusOK, gin.H{"redirect": authorizationRedirect(req, url.Values{"error": {"access_denied"}})})
+		return
+	}
+
+	authTime := time.Now()
+	if t, ok := c.Get("authTime"); ok {
+		authTime = t.(time.Time)
+	}
+
+	code, err := services.CreateAuthorizationCode(ctx, userID, client, req, scopes, authTime)
+	if err != nil {
+		log.Printf("Failed to create authorization code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to authorize"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Signed in to %s", client.Name))
+
+	c.JSON(http.StatusOK, gin.H{"redirect": authorizationRedirect(req, url.Values{"code": {code}})})
+}
+
+// POST /oauth/token — Token endpoint for the authorization_code,
+// refresh_token and device_code grants. Clients authenticate with HTTP Basic or
+// client_id/client_secret form fields; public clients send client_id only.
+func Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	ctx := context.Background()
+
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	var (
+		resp *models.OAuthTokenResponse
+		err  error
+	)
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		resp, err = services.ExchangeAuthorizationCode(ctx, client,
+			c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+	case "refresh_token":
+		resp, err = services.RefreshOAuthTokens(ctx, client, c.PostForm("refresh_token"), c.PostForm("scope"))
+	case deviceCodeGrantType:
+		resp, err = services.ExchangeDeviceCode(ctx, client, c.PostForm("device_code"))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	if err != nil {
+		if isTokenGrantError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": oauthErrorCode(err), "error_description": err.Error()})
+			return
+		}
+		log.Printf("Failed to issue tokens to %s: %v", client.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// isTokenGrantError reports whether err is the client's fault rather than
+// a server error.
+func isTokenGrantError(err error) bool {
+	for _, target := range []error{
+		services.ErrInvalidGrant, services.ErrInvalidScope,
+		services.ErrAuthorizationPending, services.ErrSlowDown,
+		services.ErrExpiredToken, services.ErrAccessDenied,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /userinfo — Claims about the user an app's access token belongs to
+func UserInfo(c *gin.Context) {
+	ctx := context.Background()
+
+	tokenString, _ := middleware.BearerToken(c)
+	claims, err := services.ValidateOAuthAccessToken(ctx, tokenString)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	user, err := services.FindUserByID(ctx, claims.Subject)
+	if err != nil || user.DeletedAt != nil || isSuspended(ctx, user.ID) {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.UserInfoClaims(user, strings.Fields(claims.Scope)))
+}
+
+// authenticateClient resolves the calling client at the token endpoint
+// and writes an invalid_client error otherwise.
+func authenticateClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 §2.3.1: both are form-urlencoded inside the header
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := services.AuthenticateClient(context.Background(), clientID, secret)
+	if err != nil {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		if errors.Is(err, services.ErrInvalidClient) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		}
+		return nil, false
+	}
+	return client, true
+}
+
+// authorizationRedirect sends params (plus state and iss) back to the
+// client's redirect URI.
+func authorizationRedirect(req models.AuthorizationRequest, params url.Values) string {
+	u, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return req.RedirectURI
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	q.Set("iss", services.Issuer())
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// oauthErrorCode maps service errors to OAuth 2.0 error codes.
+func oauthErrorCode(err error) string {
+	switch {
+	case errors.Is(err, services.ErrUnsupportedResponseType):
+		return "unsupported_response_type"
+	case errors.Is(err, services.ErrInvalidScope):
+		return "invalid_scope"
+	case errors.Is(err, services.ErrInvalidGrant):
+		return "invalid_grant"
+	case errors.Is(err, services.ErrInvalidClient):
+		return "invalid_client"
+	case errors.Is(err, services.ErrAuthorizationPending):
+		return "authorization_pending"
+	case errors.Is(err, services.ErrSlowDown):
+		return "slow_down"
+	case errors.Is(err, services.ErrExpiredToken):
+		return "expired_token"
+	case errors.Is(err, services.ErrAccessDenied):
+		return "access_denied"
+	default:
+		return "invalid_request"
+	}
+}
+
+// POST /oauth/introspect — RFC 7662 token introspection for resource
+// servers. Only confidential clients may introspect.
+func IntrospectToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+	if client.Public {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": "public clients cannot introspect tokens"})
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "token is required"})
+		return
+	}
+
+	result, err := services.IntrospectToken(context.Background(), client, token)
+	if err != nil {
+		log.Printf("Failed to introspect token for %s: %v", client.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// POST /oauth/revoke — RFC 7009 revocation of a token issued to the
+// calling client. Unknown tokens are not an error.
+func RevokeToken(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "token is required"})
+		return
+	}
+
+	if err := services.RevokeClientToken(context.Background(), client, token); err != nil {
+		log.Printf("Failed to revoke token for %s: %v", client.ID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// POST /oauth/device/code — RFC 8628 device authorization. Returns the
+// codes a device shows the user and polls /oauth/token with.
+func DeviceAuthorization(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	resp, err := services.RequestDeviceCode(context.Background(), client, c.PostForm("scope"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+			return
+		}
+		log.Printf("Failed to start device authorization for %s: %v", client.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	resp.VerificationURI = frontendBaseURL() + "/device"
+	resp.VerificationURIComplete = resp.VerificationURI + "?" + url.Values{"user_code": {resp.UserCode}}.Encode()
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /api/oauth/device?user_code= — Consent screen data for the device
+// the user is signing in on
+func GetDeviceConsent(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	screen, err := services.GetDeviceConsent(context.Background(), userID, c.Query("user_code"))
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceCodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load consent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, screen)
+}
+
+// POST /api/oauth/device — Approves or denies the device with a user code
+func DecideDeviceAuthorization(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	var decision models.DeviceDecision
+	if err := c.ShouldBindJSON(&decision); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_code is required"})
+		return
+	}
+
+	authTime := time.Now()
+	if t, ok := c.Get("authTime"); ok {
+		authTime = t.(time.Time)
+	}
+
+	client, err := services.DecideDeviceAuthorization(ctx, userID, decision.UserCode, decision.Approved, authTime)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceCodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to decide device authorization: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to authorize device"})
+		return
+	}
+
+	if decision.Approved {
+		logActivity(c, userID, fmt.Sprintf("Signed in to %s on a device", client.Name))
+	} else {
+		logActivity(c, userID, fmt.Sprintf("Denied a device sign-in to %s", client.Name))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"approved": decision.Approved})
+}
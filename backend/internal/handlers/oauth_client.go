@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/admin/oauth-clients
+func GetOAuthClients(c *gin.Context) {
+	clients, err := services.GetOAuthClients(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch clients"})
+		return
+	}
+
+	if clients == nil {
+		clients = []models.OAuthClient{}
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// POST /api/admin/oauth-clients — The client secret is only returned in
+// this response
+func CreateOAuthClient(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and at least one redirect URI are required"})
+		return
+	}
+
+	client, secret, err := services.CreateOAuthClient(context.Background(), userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRedirectURI) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create client"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Registered OAuth client %q", client.Name))
+
+	resp := gin.H{"client": client}
+	if secret != "" {
+		resp["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// DELETE /api/admin/oauth-clients/:id — Disables the client and revokes
+// every token issued to it
+func RevokeOAuthClient(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	if err := services.RevokeOAuthClient(context.Background(), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrOAuthClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke client"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Revoked OAuth client %s", c.Param("id")))
+
+	c.JSON(http.StatusOK, gin.H{"message": "client revoked"})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/users/me/passkeys
+func GetPasskeys(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	passkeys, err := services.GetPasskeys(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch passkeys"})
+		return
+	}
+
+	if passkeys == nil {
+		passkeys = []models.Passkey{}
+	}
+
+	c.JSON(http.StatusOK, passkeys)
+}
+
+// POST /api/users/me/passkeys/begin — Returns options for navigator.credentials.create()
+func BeginPasskeyRegistration(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	creation, challengeID, err := services.BeginPasskeyRegistration(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start passkey registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenge_id": challengeID, "options": creation})
+}
+
+// POST /api/users/me/passkeys — Stores the passkey created by the browser
+func FinishPasskeyRegistration(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.PasskeyCeremonyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_id and credential are required"})
+		return
+	}
+
+	ctx := context.Background()
+	passkey, recoveryCodes, err := services.FinishPasskeyRegistration(ctx, userID, req.ChallengeID, req.Name, req.Credential)
+	if err != nil {
+		if isPasskeyRejection(err) {
+			log.Printf("Passkey registration failed: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "passkey registration failed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save passkey"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Registered passkey %q", passkey.Name))
+
+	resp := gin.H{"passkey": passkey}
+	if len(recoveryCodes) > 0 {
+		resp["recovery_codes"] = recoveryCodes
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// DELETE /api/users/me/passkeys/:id
+func DeletePasskey(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	if err := services.DeletePasskey(context.Background(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "passkey not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove passkey"})
+		return
+	}
+
+	logActivity(c, userID, "Removed a passkey")
+
+	c.JSON(http.StatusOK, gin.H{"message": "passkey removed"})
+}
+
+// POST /auth/passkey/begin — Starts a passwordless login with any passkey for this site
+func BeginPasskeyLogin(c *gin.Context) {
+	assertion, challengeID, err := services.BeginPasskeyLogin(context.Background(), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start passkey login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenge_id": challengeID, "options": assertion})
+}
+
+// POST /auth/passkey/finish — Verifies the assertion and starts a session
+func FinishPasskeyLogin(c *gin.Context) {
+	var req models.PasskeyCeremonyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_id and credential are required"})
+		return
+	}
+
+	ctx := context.Background()
+	userID, err := services.FinishPasskeyLogin(ctx, req.ChallengeID, req.Credential)
+	if err != nil {
+		if isPasskeyRejection(err) {
+			log.Printf("Passkey login failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey verification failed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify passkey"})
+		return
+	}
+
+	user, err := services.FindUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.DeletedAt != nil {
+		setRestoreCookie(c, user.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "pending_deletion", "purge_after": user.PurgeAfter})
+		return
+	}
+	if isSuspended(ctx, user.ID) {
+		setSuspensionNoticeCookie(c, user.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "account_suspended"})
+		return
+	}
+
+	if err := services.IncrementLoginCount(ctx, user.ID); err != nil {
+		log.Printf("Failed to increment login count: %v", err)
+	}
+	_ = services.LogActivity(ctx, user.ID, "Logged in with a passkey")
+
+	// A passkey already proves possession and user verification: no second factor
+	session, err := services.CreateSession(ctx, user.ID, c.ClientIP(), c.Request.UserAgent(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	if err := services.MarkSecondFactor(ctx, session.ID); err != nil {
+		log.Printf("Failed to record second factor: %v", err)
+	}
+
+	accessToken, refreshToken, err := services.IssueTokens(ctx, user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+
+	middleware.SetAuthCookies(c, accessToken, refreshToken)
+	c.JSON(http.StatusOK, gin.H{"redirect": frontendBaseURL() + "/dashboard"})
+}
+
+// isPasskeyRejection reports whether err means the client's ceremony was
+// refused (as opposed to a server failure).
+func isPasskeyRejection(err error) bool {
+	return errors.Is(err, services.ErrInvalidPasskey) ||
+		errors.Is(err, services.ErrInvalidChallenge) ||
+		errors.Is(err, services.ErrPasskeyCloneWarning) ||
+		errors.Is(err, services.ErrPasskeyNotFound)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
 	"github.com/oauth-app/backend/internal/services"
 )
 
@@ -13,15 +14,24 @@ func GetPublicProfile(c *gin.Context) {
 	username := c.Param("username")
 
 	user, err := services.FindUserByUsername(context.Background(), username)
-	if err != nil {
+	if err != nil || user.DeletedAt != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	// Check if the viewer is the profile owner (via JWT cookie)
+	// Suspended and banned accounts are hidden like deleted ones
+	if suspension, err := services.GetActiveSuspension(context.Background(), user.ID); err != nil || suspension != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Check if the viewer is the profile owner (via bearer token or JWT cookie)
 	isOwner := false
-	tokenString, cookieErr := c.Cookie("token")
-	if cookieErr == nil && tokenString != "" {
+	tokenString, ok := middleware.BearerToken(c)
+	if !ok {
+		tokenString, _ = c.Cookie(middleware.AccessTokenCookie)
+	}
+	if tokenString != "" {
 		claims, claimErr := services.ValidateJWT(tokenString)
 		if claimErr == nil && claims.UserID == user.ID {
 			isOwner = true
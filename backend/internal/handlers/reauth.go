@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
+	"github.com/oauth-app/backend/internal/services"
+	"golang.org/x/oauth2"
+)
+
+// GET /auth/reauth/:provider — Sends the user through a forced fresh login
+// at the provider; the callback refreshes the session's auth_time
+func ReauthWithProvider(c *gin.Context) {
+	provider, ok := providers.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return
+	}
+	if !providers.SupportsReauth(provider) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider does not support re-authentication"})
+		return
+	}
+
+	state, err := services.NewOAuthState(provider.Name())
+	if err != nil {
+		log.Printf("Failed to create OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start re-authentication"})
+		return
+	}
+	state.ReauthUserID = fmt.Sprintf("%v", c.MustGet("userID"))
+	state.ReauthSessionID = fmt.Sprintf("%v", c.MustGet("sessionID"))
+	state.ReturnTo = services.SafeReturnPath(c.Query("return_to"))
+
+	cookie, err := services.SignOAuthState(state)
+	if err != nil {
+		log.Printf("Failed to sign OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start re-authentication"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OAuthStateCookie, cookie, int(services.OAuthStateTTL.Seconds()), "/auth", "", false, true)
+
+	// max_age=0 is the OIDC way to demand an interactive login (Google
+	// honours it); the callback checks auth_time to be sure it happened
+	url := provider.AuthCodeURL(state.State,
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", state.Nonce),
+		oauth2.SetAuthURLParam("max_age", "0"))
+	c.Redirect(http.StatusTemporaryRedirect, url)
+}
+
+// completeProviderReauth finishes ReauthWithProvider from ProviderCallback.
+func completeProviderReauth(c *gin.Context, state *services.OAuthState, profile *providers.Profile) {
+	ctx := context.Background()
+	returnURL := frontendBaseURL() + state.ReturnTo
+
+	// The provider must confirm a login that happened after we asked for it
+	startedAt := time.Unix(state.ExpiresAt, 0).Add(-services.OAuthStateTTL)
+	if profile.AuthTime.IsZero() || profile.AuthTime.Before(startedAt.Add(-time.Minute)) {
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "reauth_not_fresh"))
+		return
+	}
+
+	user, err := services.FindUserByIdentity(ctx, profile.Provider, profile.Subject)
+	if err != nil || user.ID != state.ReauthUserID {
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "reauth_wrong_account"))
+		return
+	}
+
+	accessToken, err := services.ReauthenticateSession(ctx, state.ReauthUserID, state.ReauthSessionID, false)
+	if err != nil {
+		log.Printf("Failed to re-authenticate session: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, withQuery(returnURL, "error", "session_failed"))
+		return
+	}
+
+	logActivity(c, user.ID, fmt.Sprintf("Re-authenticated with %s", profile.Provider))
+
+	middleware.SetAuthCookies(c, accessToken, "")
+	c.Redirect(http.StatusTemporaryRedirect, returnURL)
+}
+
+// POST /auth/reauth/totp — Re-authenticates with a TOTP or recovery code
+func ReauthWithCode(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	// Counts against the per-user code lockout before auth_time can move
+	if !verifySecondFactor(c, userID) {
+		return
+	}
+
+	respondReauthenticated(c, userID, "a verification code")
+}
+
+// POST /auth/reauth/passkey/begin
+func BeginReauthPasskey(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	assertion, challengeID, err := services.BeginPasskeyLogin(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no passkeys registered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start passkey verification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenge_id": challengeID, "options": assertion})
+}
+
+// POST /auth/reauth/passkey/finish
+func FinishReauthPasskey(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.PasskeyCeremonyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_id and credential are required"})
+		return
+	}
+
+	if err := services.VerifyPasskey(context.Background(), userID, req.ChallengeID, req.Credential); err != nil {
+		if isPasskeyRejection(err) {
+			log.Printf("Passkey verification failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey verification failed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify passkey"})
+		return
+	}
+
+	respondReauthenticated(c, userID, "a passkey")
+}
+
+// respondReauthenticated refreshes the session's auth_time after a step-up
+// with a second factor and hands out the new access token the same way the
+// client sent the old one.
+func respondReauthenticated(c *gin.Context, userID, method string) {
+	ctx := context.Background()
+	sessionID := fmt.Sprintf("%v", c.MustGet("sessionID"))
+
+	accessToken, err := services.ReauthenticateSession(ctx, userID, sessionID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to re-authenticate"})
+		return
+	}
+
+	logActivity(c, userID, fmt.Sprintf("Re-authenticated with %s", method))
+
+	if _, ok := middleware.BearerToken(c); ok {
+		c.JSON(http.StatusOK, newTokenResponse(accessToken, ""))
+		return
+	}
+
+	middleware.SetAuthCookies(c, accessToken, "")
+	c.JSON(http.StatusOK, gin.H{"message": "re-authenticated"})
+}
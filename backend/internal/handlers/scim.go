@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /scim/v2/ServiceProviderConfig — What this SCIM service supports
+func GetSCIMServiceProviderConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"schemas":        []string{models.SCIMSchemaServiceProviderConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": services.MaxSCIMPageSize},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "A tenant token from /api/admin/scim-tenants",
+		}},
+	})
+}
+
+// GET /scim/v2/Users?filter=&startIndex=&count=
+func ListSCIMUsers(c *gin.Context) {
+	tenant := c.MustGet("scimTenant").(*models.SCIMTenant)
+
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(services.MaxSCIMPageSize)))
+	if err != nil || count < 0 || count > services.MaxSCIMPageSize {
+		count = services.MaxSCIMPageSize
+	}
+
+	list, err := services.ListSCIMUsers(context.Background(), tenant, c.Query("filter"), startIndex, count)
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /scim/v2/Users/:id
+func GetSCIMUser(c *gin.Context) {
+	tenant := c.MustGet("scimTenant").(*models.SCIMTenant)
+
+	user, err := services.GetSCIMUser(context.Background(), tenant, c.Param("id"))
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// POST /scim/v2/Users — Provisions an account before its first login
+func CreateSCIMUser(c *gin.Context) {
+	tenant := c.MustGet("scimTenant").(*models.SCIMTenant)
+	ctx := context.Background()
+
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "request body must be a SCIM User")
+		return
+	}
+
+	user, err := services.CreateSCIMUser(ctx, tenant, req)
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	_ = services.LogActivity(ctx, user.ID, fmt.Sprintf("Account provisioned by %s", tenant.Name))
+	if !*user.Active {
+		_ = services.LogActivity(ctx, user.ID, fmt.Sprintf("Account deactivated by %s", tenant.Name))
+	}
+
+	c.Header("Location", user.Meta.Location)
+	c.JSON(http.StatusCreated, user)
+}
+
+// PUT /scim/v2/Users/:id — Replaces the user's attributes
+func ReplaceSCIMUser(c *gin.Context) {
+	tenant := c.MustGet("scimTenant").(*models.SCIMTenant)
+	ctx := context.Background()
+
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "request body must be a SCIM User")
+		return
+	}
+
+	before, err := services.GetSCIMUser(ctx, tenant, c.Param("id"))
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	user, err := services.ReplaceSCIMUser(ctx, tenant, before.ID, req)
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	logSCIMUpdate(ctx, tenant, before, user)
+	c.JSON(http.StatusOK, user)
+}
+
+// PATCH /scim/v2/Users/:id — Applies add/replace/remove operations;
+// {"op": "replace", "path": "active", "value": false} deactivates the user
+func PatchSCIMUser(c *gin.Context) {
+	tenant := c.MustGet("scimTenant").(*models.SCIMTenant)
+	ctx := context.Background()
+
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "request body must be a PatchOp with Operations")
+		return
+	}
+
+	before, err := services.GetSCIMUser(ctx, tenant, c.Param("id"))
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	user, err := services.PatchSCIMUser(ctx, tenant, before, req.Operations)
+	if err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	logSCIMUpdate(ctx, tenant, before, user)
+	c.JSON(http.StatusOK, user)
+}
+
+// DELETE /scim/v2/Users/:id — Deactivates the user and schedules the
+// account for deletion
+func DeleteSCIMUser(c *gin.Context) {
+	tenant := c.MustGet("scimTenant").(*models.SCIMTenant)
+	ctx := context.Background()
+
+	if err := services.DeleteSCIMUser(ctx, tenant, c.Param("id")); err != nil {
+		scimServiceError(c, err)
+		return
+	}
+
+	_ = services.LogActivity(ctx, c.Param("id"), fmt.Sprintf("Account deleted by %s", tenant.Name))
+
+	c.Status(http.StatusNoContent)
+}
+
+// logSCIMUpdate records a directory's changes in the user's activity log.
+func logSCIMUpdate(ctx context.Context, tenant *models.SCIMTenant, before, after *models.SCIMUser) {
+	switch {
+	case *before.Active && !*after.Active:
+		_ = services.LogActivity(ctx, after.ID, fmt.Sprintf("Account deactivated by %s", tenant.Name))
+	case !*before.Active && *after.Active:
+		_ = services.LogActivity(ctx, after.ID, fmt.Sprintf("Account reactivated by %s", tenant.Name))
+	}
+	if before.UserName != after.UserName {
+		_ = services.LogActivity(ctx, after.ID, fmt.Sprintf("Email changed to %s by %s", after.UserName, tenant.Name))
+	}
+}
+
+// scimServiceError maps service errors to SCIM error responses.
+func scimServiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		scimError(c, http.StatusNotFound, "", "user not found")
+	case errors.Is(err, services.ErrSCIMUniqueness):
+		scimError(c, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, services.ErrSCIMInvalidFilter):
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, services.ErrSCIMInvalidPath):
+		scimError(c, http.StatusBadRequest, "invalidPath", err.Error())
+	case errors.Is(err, services.ErrSCIMInvalidValue):
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+	default:
+		log.Printf("SCIM request failed: %v", err)
+		scimError(c, http.StatusInternalServerError, "", "internal error")
+	}
+}
+
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	c.JSON(status, models.SCIMError{
+		Schemas:  []string{models.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
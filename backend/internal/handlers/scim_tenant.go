@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/admin/scim-tenants
+func GetSCIMTenants(c *gin.Context) {
+	tenants, err := services.GetSCIMTenants(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch SCIM tenants"})
+		return
+	}
+
+	if tenants == nil {
+		tenants = []models.SCIMTenant{}
+	}
+
+	c.JSON(http.StatusOK, tenants)
+}
+
+// POST /api/admin/scim-tenants — The bearer token is only returned in this
+// response
+func CreateSCIMTenant(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	var req models.CreateSCIMTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	tenant, token, err := services.CreateSCIMTenant(context.Background(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create SCIM tenant"})
+		return
+	}
+
+	_ = services.LogActivity(context.Background(), userID, fmt.Sprintf("Created SCIM tenant %q", tenant.Name))
+
+	c.JSON(http.StatusCreated, gin.H{"tenant": tenant, "token": token})
+}
+
+// DELETE /api/admin/scim-tenants/:id — Revokes the tenant's token; its
+// users are kept
+func RevokeSCIMTenant(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	if err := services.RevokeSCIMTenant(context.Background(), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSCIMTenantNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke SCIM tenant"})
+		return
+	}
+
+	_ = services.LogActivity(context.Background(), userID, fmt.Sprintf("Revoked SCIM tenant %s", c.Param("id")))
+
+	c.JSON(http.StatusOK, gin.H{"message": "SCIM tenant revoked"})
+}
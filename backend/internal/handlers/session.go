@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /api/users/me/sessions
+func GetSessions(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	currentID := c.GetString("sessionID")
+
+	sessions, err := services.GetActiveSessions(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// DELETE /api/users/me/sessions/:id — Revoke a single session
+func RevokeSession(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	sessionID := c.Param("id")
+
+	if err := services.RevokeSession(context.Background(), userID, sessionID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	logActivity(c, userID, "Revoked a session")
+
+	if sessionID == c.GetString("sessionID") {
+		middleware.ClearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// DELETE /api/users/me/sessions — Log out everywhere
+func RevokeAllSessions(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	count, err := services.RevokeAllSessions(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	logActivity(c, userID, "Logged out of all sessions")
+
+	middleware.ClearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions revoked", "revoked": count})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// GET /auth/suspension — Why the account is suspended. Requires the notice
+// cookie set when a suspended user tried to sign in.
+func GetSuspensionNotice(c *gin.Context) {
+	tokenString, _ := c.Cookie(services.SuspensionNoticeCookie)
+	userID, err := services.ValidateSuspensionNoticeToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in again to see why your account is suspended"})
+		return
+	}
+
+	notice, err := services.GetSuspensionNotice(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch suspension"})
+		return
+	}
+	if notice == nil {
+		c.JSON(http.StatusOK, gin.H{"suspended": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suspended": true, "suspension": notice})
+}
+
+// setSuspensionNoticeCookie lets the browser show a suspended user why they
+// cannot sign in.
+func setSuspensionNoticeCookie(c *gin.Context, userID string) {
+	token, err := services.GenerateSuspensionNoticeToken(userID)
+	if err != nil {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.SuspensionNoticeCookie, token, int(services.SuspensionNoticeTTL.Seconds()), "/auth/suspension", "", false, true)
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/middleware"
 	"github.com/oauth-app/backend/internal/models"
 	"github.com/oauth-app/backend/internal/services"
 )
@@ -37,7 +39,7 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	_ = services.LogActivity(context.Background(), userID, "Updated profile")
+	logActivity(c, userID, "Updated profile")
 
 	c.JSON(http.StatusOK, user)
 }
@@ -61,11 +63,61 @@ func UpdateUsername(c *gin.Context) {
 		return
 	}
 
-	_ = services.LogActivity(context.Background(), userID, fmt.Sprintf("Changed username to %s", req.Username))
+	logActivity(c, userID, fmt.Sprintf("Changed username to %s", req.Username))
 
 	c.JSON(http.StatusOK, gin.H{"message": "username updated"})
 }
 
+// GET /api/users/me/profile-sync — How name, email and image follow the
+// login provider
+func GetProfileSync(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+	settings, err := services.GetProfileSync(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sync settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// PUT /api/users/me/profile-sync — Body maps field to policy, e.g.
+// {"name": "never", "email": "always"}
+func UpdateProfileSync(c *gin.Context) {
+	userID := fmt.Sprintf("%v", c.MustGet("userID"))
+	ctx := context.Background()
+
+	var req map[string]string
+	if err := c.ShouldBindJSON(&req); err != nil || len(req) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := services.UpdateProfileSync(ctx, userID, req); err != nil {
+		if errors.Is(err, services.ErrUnknownProfileField) || errors.Is(err, services.ErrInvalidSyncPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    err.Error(),
+				"fields":   models.SyncableProfileFields,
+				"policies": models.SyncPolicies,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update sync settings"})
+		return
+	}
+
+	logActivity(c, userID, "Updated profile sync settings")
+
+	settings, err := services.GetProfileSync(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sync settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
 // PUT /api/users/me/toggle-public
 func TogglePublic(c *gin.Context) {
 	userID := fmt.Sprintf("%v", c.MustGet("userID"))
@@ -88,25 +140,65 @@ func TogglePublic(c *gin.Context) {
 	if newPublic {
 		action = "Set profile to public"
 	}
-	_ = services.LogActivity(context.Background(), userID, action)
+	logActivity(c, userID, action)
 
 	c.JSON(http.StatusOK, updated)
 }
 
-// DELETE /api/users/me — Permanently delete user and all related data
+// DELETE /api/users/me — Schedules the account for deletion. It is hidden
+// and signed out everywhere now, and purged after the grace period.
 func DeleteUser(c *gin.Context) {
 	userID := fmt.Sprintf("%v", c.MustGet("userID"))
 
-	if err := services.HardDeleteUser(context.Background(), userID); err != nil {
+	purgeAfter, err := services.SoftDeleteUser(context.Background(), userID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
 		return
 	}
 
+	logActivity(c, userID, "Requested account deletion")
+
 	// Clear auth cookie
+	middleware.ClearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "account scheduled for deletion", "purge_after": purgeAfter})
+}
+
+// POST /auth/restore — Cancels a pending deletion. Requires the restore
+// cookie set when the user signed in to the deleted account.
+func RestoreAccount(c *gin.Context) {
+	tokenString, _ := c.Cookie(services.RestoreTokenCookie)
+	userID, err := services.ValidateRestoreToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in again to restore your account"})
+		return
+	}
+
+	if err := services.RestoreUser(context.Background(), userID); err != nil {
+		if errors.Is(err, services.ErrAccountNotPendingDeletion) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restore account"})
+		return
+	}
+
+	logActivity(c, userID, "Restored account")
+
 	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("token", "", -1, "/", "", false, true)
+	c.SetCookie(services.RestoreTokenCookie, "", -1, "/auth/restore", "", false, true)
 
-	c.JSON(http.StatusOK, gin.H{"message": "account permanently deleted"})
+	c.JSON(http.StatusOK, gin.H{"message": "account restored, please sign in again"})
+}
+
+// setRestoreCookie lets the browser restore an account pending deletion.
+func setRestoreCookie(c *gin.Context, userID string) {
+	token, err := services.GenerateRestoreToken(userID)
+	if err != nil {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.RestoreTokenCookie, token, int(services.RestoreTokenTTL.Seconds()), "/auth/restore", "", false, true)
 }
 
 // GET /api/users/me/stats
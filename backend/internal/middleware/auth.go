@@ -1,31 +1,173 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oauth-app/backend/internal/services"
 )
 
+var errMissingToken = errors.New("missing token")
+
+// AuthMiddleware authenticates a user session (cookie or Bearer access
+// token). Personal access tokens are rejected; see RequireScope.
 func AuthMiddleware() gin.HandlerFunc {
+	return authMiddleware("")
+}
+
+// RequireScope authenticates like AuthMiddleware and additionally accepts
+// personal access tokens that were granted scope. Sessions carry every scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return authMiddleware(scope)
+}
+
+func authMiddleware(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("token")
-		if err != nil || tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-			c.Abort()
+		if tokenString, ok := BearerToken(c); ok && services.IsPersonalAccessToken(tokenString) {
+			authenticateAccessToken(c, tokenString, scope)
 			return
 		}
 
-		claims, err := services.ValidateJWT(tokenString)
+		claims, err := authenticate(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		// The token must belong to a live (not revoked or expired) session
+		if err := services.TouchSession(context.Background(), claims.ID, claims.UserID, c.ClientIP()); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked or expired"})
+			c.Abort()
+			return
+		}
+
+		if rejectSuspended(c, claims.UserID) {
+			ClearAuthCookies(c)
+			return
+		}
+
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.ID)
+		if claims.AuthTime != nil {
+			c.Set("authTime", claims.AuthTime.Time)
+		}
+		if claims.Act != nil {
+			c.Set("impersonatorID", claims.Act.Subject)
+		}
 		c.Next()
 	}
 }
+
+// authenticateAccessToken handles requests made with a personal access token.
+func authenticateAccessToken(c *gin.Context, tokenString, scope string) {
+	if scope == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "personal access tokens cannot be used on this endpoint"})
+		c.Abort()
+		return
+	}
+
+	token, err := services.ValidateAccessToken(context.Background(), tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	if rejectSuspended(c, token.UserID) {
+		return
+	}
+
+	if !slices.Contains(token.Scopes, scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "required_scope": scope})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", token.UserID)
+	c.Set("accessTokenID", token.ID)
+	c.Set("scopes", token.Scopes)
+	c.Next()
+}
+
+// rejectSuspended aborts the request when the user is suspended or banned.
+// Sessions and tokens are revoked on suspension; any found still live (for
+// instance from a suspension that raced a login) are revoked here.
+func rejectSuspended(c *gin.Context, userID string) bool {
+	ctx := context.Background()
+
+	notice, err := services.GetSuspensionNotice(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check account status"})
+		c.Abort()
+		return true
+	}
+	if notice == nil {
+		return false
+	}
+
+	if err := services.RevokeSuspendedUserSessions(ctx, userID); err != nil {
+		log.Printf("Failed to revoke sessions of suspended user: %v", err)
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "account_suspended", "suspension": notice})
+	c.Abort()
+	return true
+}
+
+// authenticate resolves the caller's access token. An Authorization: Bearer
+// header takes precedence over cookies: when present it is the only
+// credential considered, and no transparent refresh happens (API clients
+// refresh explicitly via POST /auth/refresh).
+//
+// Otherwise the access token cookie is used. When it is missing or expired
+// but a refresh token cookie is present, the refresh token is rotated
+// transparently and new cookies are set on the response.
+func authenticate(c *gin.Context) (*services.JWTClaims, error) {
+	if c.GetHeader("Authorization") != "" {
+		tokenString, ok := BearerToken(c)
+		if !ok {
+			return nil, errMissingToken
+		}
+		return services.ValidateJWT(tokenString)
+	}
+
+	tokenString, err := c.Cookie(AccessTokenCookie)
+	if err == nil && tokenString != "" {
+		claims, err := services.ValidateJWT(tokenString)
+		if err == nil {
+			return claims, nil
+		}
+	}
+
+	refreshToken, err := c.Cookie(RefreshTokenCookie)
+	if err != nil || refreshToken == "" {
+		return nil, errMissingToken
+	}
+
+	accessToken, newRefreshToken, err := services.RefreshTokens(context.Background(), refreshToken)
+	if err != nil {
+		ClearAuthCookies(c)
+		return nil, err
+	}
+
+	SetAuthCookies(c, accessToken, newRefreshToken)
+	return services.ValidateJWT(accessToken)
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header.
+func BearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
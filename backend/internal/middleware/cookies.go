@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+const (
+	AccessTokenCookie  = "token"
+	RefreshTokenCookie = "refresh_token"
+)
+
+// SetAuthCookies stores the access token and, when non-empty, the refresh
+// token in HTTP-only cookies.
+func SetAuthCookies(c *gin.Context, accessToken, refreshToken string) {
+	secure := false // Set to true in production with HTTPS
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(AccessTokenCookie, accessToken, int(services.AccessTokenTTL.Seconds()), "/", "", secure, true)
+	if refreshToken != "" {
+		c.SetCookie(RefreshTokenCookie, refreshToken, int(services.SessionTTL.Seconds()), "/", "", secure, true)
+	}
+}
+
+// ClearAuthCookies removes both auth cookies.
+func ClearAuthCookies(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(AccessTokenCookie, "", -1, "/", "", false, true)
+	c.SetCookie(RefreshTokenCookie, "", -1, "/", "", false, true)
+}
+
+// ClearAccessCookie removes only the access token cookie. The refresh
+// token cookie, if any, signs the browser back in to its own session.
+func ClearAccessCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(AccessTokenCookie, "", -1, "/", "", false, true)
+}
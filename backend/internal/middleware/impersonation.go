@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlockImpersonation rejects requests from impersonation sessions. Use it
+// after AuthMiddleware on endpoints an administrator must not use on a
+// user's behalf (account deletion, credentials, sessions).
+func BlockImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("impersonatorID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not_allowed_while_impersonating"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// RequirePermission only lets users whose role grants permission through.
+// Use it after AuthMiddleware. The role is read from the database rather
+// than the token, so role changes apply immediately.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+		role, err := services.GetUserRole(context.Background(), userID)
+		if err != nil || !services.HasPermission(role, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_permission", "required_permission": permission})
+			c.Abort()
+			return
+		}
+
+		c.Set("role", role)
+		c.Next()
+	}
+}
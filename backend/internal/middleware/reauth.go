@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// RequireRecentAuth guards sensitive endpoints: the session must have
+// authenticated (login or step-up) within services.ReauthMaxAge. Use it
+// after AuthMiddleware or RequireScope. Otherwise the client gets a
+// reauth_required error listing the methods it can use to step up.
+func RequireRecentAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isPAT := c.Get("accessTokenID"); isPAT {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action requires an interactive session"})
+			c.Abort()
+			return
+		}
+
+		if authenticatedRecently(c) {
+			c.Next()
+			return
+		}
+
+		userID := fmt.Sprintf("%v", c.MustGet("userID"))
+		methods, err := services.GetReauthMethods(context.Background(), userID)
+		if err != nil {
+			methods = []string{}
+		}
+		abortStepUp(c, "reauth_required", methods)
+	}
+}
+
+// RequireRecentSecondFactor guards changes to the second factors. Until
+// the user has one it behaves like RequireRecentAuth; after that the
+// session must have presented a code or passkey within
+// services.ReauthMaxAge, so a login provider alone cannot swap them out.
+func RequireRecentSecondFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isPAT := c.Get("accessTokenID"); isPAT {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action requires an interactive session"})
+			c.Abort()
+			return
+		}
+
+		ctx := context.Background()
+		userID := fmt.Sprintf("%v", c.MustGet("userID"))
+
+		enabled, err := services.IsMFAEnabled(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check two-factor status"})
+			c.Abort()
+			return
+		}
+		if !enabled {
+			RequireRecentAuth()(c)
+			return
+		}
+
+		recent, err := services.HasRecentSecondFactor(ctx, userID, c.GetString("sessionID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check two-factor status"})
+			c.Abort()
+			return
+		}
+		if recent {
+			c.Next()
+			return
+		}
+
+		methods, err := services.GetMFAMethods(ctx, userID)
+		if err != nil {
+			methods = []string{}
+		}
+		abortStepUp(c, "second_factor_required", methods)
+	}
+}
+
+func authenticatedRecently(c *gin.Context) bool {
+	authTime, _ := c.Get("authTime")
+	t, ok := authTime.(time.Time)
+	return ok && time.Since(t) <= services.ReauthMaxAge
+}
+
+func abortStepUp(c *gin.Context, code string, methods []string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   code,
+		"max_age": int(services.ReauthMaxAge.Seconds()),
+		"methods": methods,
+	})
+	c.Abort()
+}
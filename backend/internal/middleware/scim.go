@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/services"
+)
+
+// SCIMAuth authenticates a SCIM tenant by its bearer token and sets
+// "scimTenant". Responses use the SCIM media type.
+func SCIMAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/scim+json")
+
+		token, _ := BearerToken(c)
+		tenant, err := services.ValidateSCIMToken(context.Background(), token)
+		if err != nil {
+			if !errors.Is(err, services.ErrSCIMTenantNotFound) {
+				log.Printf("Failed to validate SCIM token: %v", err)
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			c.JSON(http.StatusUnauthorized, models.SCIMError{
+				Schemas: []string{models.SCIMSchemaError},
+				Status:  "401",
+				Detail:  "invalid or revoked token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("scimTenant", tenant)
+		c.Next()
+	}
+}
@@ -7,4 +7,6 @@ type ActivityLog struct {
 	UserID    string    `json:"user_id"`
 	Action    string    `json:"action"`
 	CreatedAt time.Time `json:"created_at"`
+	// The administrator who performed the action while impersonating the user
+	ImpersonatorID *string `json:"impersonator_id,omitempty"`
 }
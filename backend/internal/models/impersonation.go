@@ -0,0 +1,8 @@
+package models
+
+// Impersonator is the administrator acting in an impersonation session.
+type Impersonator struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
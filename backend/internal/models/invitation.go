@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type Invitation struct {
+	ID string `json:"id"`
+	// Only this (verified) address may use the invitation when set;
+	// otherwise it is an open link
+	Email     string     `json:"email"`
+	MaxUses   int        `json:"max_uses"`
+	UseCount  int        `json:"use_count"`
+	CreatedBy *string    `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// InvitationPreview is what an invitee may see before signing up.
+type InvitationPreview struct {
+	Email       string    `json:"email"`
+	InviterName string    `json:"inviter_name"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type CreateInvitationRequest struct {
+	Email         string `json:"email" binding:"omitempty,email"`
+	MaxUses       int    `json:"max_uses" binding:"min=0,max=1000"`
+	ExpiresInDays int    `json:"expires_in_days" binding:"min=0,max=90"`
+}
@@ -0,0 +1,127 @@
+package models
+
+import "time"
+
+// Scopes other applications can request through /oauth/authorize
+const (
+	OAuthScopeOpenID        = "openid"
+	OAuthScopeProfile       = "profile"
+	OAuthScopeEmail         = "email"
+	OAuthScopeOfflineAccess = "offline_access"
+)
+
+var OAuthScopes = []string{OAuthScopeOpenID, OAuthScopeProfile, OAuthScopeEmail, OAuthScopeOfflineAccess}
+
+// OAuthScopeDescriptions are shown on the consent screen.
+var OAuthScopeDescriptions = map[string]string{
+	OAuthScopeOpenID:        "Sign you in with your account",
+	OAuthScopeProfile:       "See your name, username, picture and public profile details",
+	OAuthScopeEmail:         "See your email address",
+	OAuthScopeOfflineAccess: "Keep access while you are not using the app",
+}
+
+// OAuthClient is an application registered to sign users in through this
+// backend. Its ID is the OAuth client_id.
+type OAuthClient struct {
+	ID           string     `json:"client_id"`
+	Name         string     `json:"name"`
+	Public       bool       `json:"public"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	CreatedBy    *string    `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,required"`
+	// Public clients get no secret and must use PKCE
+	Public bool `json:"public"`
+}
+
+// AuthorizationRequest holds the parameters of an OAuth 2.0 / OpenID
+// Connect authorization request.
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Prompt              string `form:"prompt" json:"prompt"`
+}
+
+// AuthorizationDecision is the user's answer on the consent screen.
+type AuthorizationDecision struct {
+	AuthorizationRequest
+	Approved bool `json:"approved"`
+}
+
+type ConsentScope struct {
+	Scope       string `json:"scope"`
+	Description string `json:"description"`
+}
+
+// ConsentScreen is what the frontend needs to ask the user for consent.
+type ConsentScreen struct {
+	ClientID   string         `json:"client_id"`
+	ClientName string         `json:"client_name"`
+	Scopes     []ConsentScope `json:"scopes"`
+	// False when the user already allowed every requested scope; the
+	// frontend may then approve without asking
+	ConsentRequired bool `json:"consent_required"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// TokenIntrospection is an RFC 7662 introspection response. Inactive
+// tokens only report active=false.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+}
+
+// DeviceAuthorizationResponse is returned by the device authorization
+// endpoint (RFC 8628 §3.2).
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceDecision is the user's answer on the device verification page.
+type DeviceDecision struct {
+	UserCode string `json:"user_code" binding:"required"`
+	Approved bool   `json:"approved"`
+}
+
+// AuthorizedApp is a client application the user has allowed to access
+// their account.
+type AuthorizedApp struct {
+	ClientID   string     `json:"client_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	GrantedAt  time.Time  `json:"granted_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
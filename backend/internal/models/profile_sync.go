@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Profile fields that can be kept in sync with the login provider
+const (
+	ProfileFieldName  = "name"
+	ProfileFieldEmail = "email"
+	ProfileFieldImage = "image"
+)
+
+// Sync policies
+const (
+	SyncAlways      = "always"
+	SyncUntilEdited = "until_edited"
+	SyncNever       = "never"
+)
+
+var SyncableProfileFields = []string{ProfileFieldName, ProfileFieldEmail, ProfileFieldImage}
+
+var SyncPolicies = []string{SyncAlways, SyncUntilEdited, SyncNever}
+
+type ProfileFieldSync struct {
+	Policy string `json:"policy"`
+	// When the user last edited the field; stops until_edited syncing
+	EditedAt *time.Time `json:"edited_at"`
+}
+
+// ProfileFieldChange is one field updated from the provider at login.
+type ProfileFieldChange struct {
+	Field    string
+	OldValue string
+	NewValue string
+}
@@ -0,0 +1,35 @@
+package models
+
+// Roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// Permissions checked by RequirePermission
+const (
+	PermUsersRead         = "users:read"
+	PermUsersActivity     = "users:activity"
+	PermUsersSuspend      = "users:suspend"
+	PermUsersDelete       = "users:delete"
+	PermUsersImpersonate  = "users:impersonate"
+	PermRolesManage       = "roles:manage"
+	PermInvitationsManage = "invitations:manage"
+	PermClientsManage     = "clients:manage"
+	PermSCIMManage        = "scim:manage"
+)
+
+// RolePermissions lists what each role may do.
+var RolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermUsersRead, PermUsersActivity, PermUsersSuspend},
+	RoleAdmin: {PermUsersRead, PermUsersActivity, PermUsersSuspend, PermUsersDelete,
+		PermUsersImpersonate, PermRolesManage, PermInvitationsManage, PermClientsManage, PermSCIMManage},
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Suspension kinds
+const (
+	SuspensionKindSuspension = "suspension"
+	SuspensionKindBan        = "ban"
+	// Set by a SCIM directory marking the user inactive
+	SuspensionKindDeactivation = "deactivation"
+)
+
+type Suspension struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Kind        string    `json:"kind"`
+	Reason      string    `json:"reason"`
+	SuspendedBy *string   `json:"suspended_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Nil for bans and open-ended suspensions
+	ExpiresAt *time.Time `json:"expires_at"`
+	LiftedAt  *time.Time `json:"lifted_at"`
+}
+
+// SuspensionNotice is what a locked-out user is shown.
+type SuspensionNotice struct {
+	Kind      string     `json:"kind"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+	// "suspension" (default) or "ban"; bans cannot expire
+	Kind           string `json:"kind" binding:"omitempty,oneof=suspension ban"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0,max=8760"`
+}
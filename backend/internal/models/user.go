@@ -4,7 +4,6 @@ import "time"
 
 type User struct {
 	ID          string    `json:"id"`
-	GoogleID    string    `json:"google_id"`
 	Name        string    `json:"name"`
 	Email       string    `json:"email"`
 	Image       string    `json:"image"`
@@ -13,10 +12,18 @@ type User struct {
 	Phone       string    `json:"phone"`
 	Location    string    `json:"location"`
 	IsPublic    bool      `json:"is_public"`
+	Role        string    `json:"role"`
 	LoginCount  int       `json:"login_count"`
 	LastLoginAt time.Time `json:"last_login_at"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// The user whose invitation was used to sign up
+	InvitedBy *string `json:"invited_by,omitempty"`
+	// Set while the account is pending deletion
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+	// Set by GET /auth/me in an impersonation session
+	ImpersonatedBy *Impersonator `json:"impersonated_by,omitempty"`
 }
 
 type UpdateUserRequest struct {
@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNonceMismatch = errors.New("id_token nonce mismatch")
+
+// IDTokenClaims are the standard claims read from a verified ID token.
+type IDTokenClaims struct {
+	Email           string           `json:"email"`
+	EmailVerified   bool             `json:"email_verified"`
+	Name            string           `json:"name"`
+	Picture         string           `json:"picture"`
+	HostedDomain    string           `json:"hd"`
+	Nonce           string           `json:"nonce"`
+	AuthorizedParty string           `json:"azp"`
+	AuthTime        *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier validates ID tokens issued by one provider for one client.
+type Verifier struct {
+	issuers  []string
+	clientID string
+	keys     *RemoteKeySet
+}
+
+// NewVerifier creates a verifier accepting tokens from any of issuers
+// (Google signs with both "https://accounts.google.com" and
+// "accounts.google.com").
+func NewVerifier(clientID string, keys *RemoteKeySet, issuers ...string) *Verifier {
+	return &Verifier{issuers: issuers, clientID: clientID, keys: keys}
+}
+
+// Verify checks the signature, iss, aud, exp and nonce of rawIDToken.
+func (v *Verifier) Verify(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return v.keys.Key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithAudience(v.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if !slices.Contains(v.issuers, claims.Issuer) {
+		return nil, fmt.Errorf("invalid id_token: unexpected issuer %q", claims.Issuer)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid id_token: missing sub")
+	}
+
+	// With multiple audiences the token must have been issued to us
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.clientID {
+		return nil, fmt.Errorf("invalid id_token: azp %q is not this client", claims.AuthorizedParty)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+
+	return claims, nil
+}
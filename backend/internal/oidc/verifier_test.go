@@ -0,0 +1,169 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeIdP is a minimal OpenID Provider serving discovery and JWKS.
+type fakeIdP struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	idp := &fakeIdP{keys: map[string]*rsa.PrivateKey{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		var set JWKSet
+		for kid, key := range idp.keys {
+			jwk, _ := NewJWK(kid, "RS256", &key.PublicKey)
+			set.Keys = append(set.Keys, jwk)
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *fakeIdP) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.keys[kid] = key
+	idp.mu.Unlock()
+	return key
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims IDTokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func newTestVerifier(t *testing.T, idp *fakeIdP) *Verifier {
+	t.Helper()
+	doc, err := Discover(context.Background(), idp.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	return NewVerifier("client-123", NewRemoteKeySet(doc.JWKSURI), doc.Issuer)
+}
+
+func validClaims(issuer string) IDTokenClaims {
+	return IDTokenClaims{
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		HostedDomain:  "example.com",
+		Nonce:         "nonce-abc",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{"client-123"},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+		},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	idp := newFakeIdP(t)
+	key := idp.addKey(t, "k1")
+	v := newTestVerifier(t, idp)
+
+	claims, err := v.Verify(context.Background(), sign(t, key, "k1", validClaims(idp.URL)), "nonce-abc")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "user-1" || !claims.EmailVerified || claims.HostedDomain != "example.com" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	idp := newFakeIdP(t)
+	key := idp.addKey(t, "k1")
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := newTestVerifier(t, idp)
+
+	tests := []struct {
+		name   string
+		mutate func(*IDTokenClaims)
+		key    *rsa.PrivateKey
+		nonce  string
+	}{
+		{"wrong audience", func(c *IDTokenClaims) { c.Audience = jwt.ClaimStrings{"someone-else"} }, key, "nonce-abc"},
+		{"wrong issuer", func(c *IDTokenClaims) { c.Issuer = "https://evil.example.com" }, key, "nonce-abc"},
+		{"expired", func(c *IDTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }, key, "nonce-abc"},
+		{"missing exp", func(c *IDTokenClaims) { c.ExpiresAt = nil }, key, "nonce-abc"},
+		{"nonce mismatch", func(c *IDTokenClaims) {}, key, "other-nonce"},
+		{"foreign azp", func(c *IDTokenClaims) {
+			c.Audience = jwt.ClaimStrings{"client-123", "client-456"}
+			c.AuthorizedParty = "client-456"
+		}, key, "nonce-abc"},
+		{"bad signature", func(c *IDTokenClaims) {}, other, "nonce-abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims(idp.URL)
+			tt.mutate(&claims)
+			if _, err := v.Verify(context.Background(), sign(t, tt.key, "k1", claims), tt.nonce); err == nil {
+				t.Error("expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	old := minRefreshInterval
+	minRefreshInterval = 0
+	defer func() { minRefreshInterval = old }()
+
+	idp := newFakeIdP(t)
+	k1 := idp.addKey(t, "k1")
+	v := newTestVerifier(t, idp)
+
+	if _, err := v.Verify(context.Background(), sign(t, k1, "k1", validClaims(idp.URL)), "nonce-abc"); err != nil {
+		t.Fatalf("Verify k1: %v", err)
+	}
+
+	// The provider publishes a new key; the cached set must be refreshed
+	k2 := idp.addKey(t, "k2")
+	if _, err := v.Verify(context.Background(), sign(t, k2, "k2", validClaims(idp.URL)), "nonce-abc"); err != nil {
+		t.Fatalf("Verify k2 after rotation: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// InitFromEnv registers every provider whose client ID is configured.
+// Callback URLs are derived from backendURL as /auth/{name}/callback.
+func InitFromEnv(backendURL string) {
+	callback := func(name string) string {
+		return backendURL + "/auth/" + name + "/callback"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if id := os.Getenv("GOOGLE_CLIENT_ID"); id != "" {
+		p, err := NewGoogle(ctx, os.Getenv("GOOGLE_ISSUER_URL"), id, os.Getenv("GOOGLE_CLIENT_SECRET"), callback("google"))
+		if err != nil {
+			log.Printf("⚠️  Google provider disabled: %v", err)
+		} else {
+			Register(p)
+		}
+	}
+
+	if id := os.Getenv("GITHUB_CLIENT_ID"); id != "" {
+		Register(NewGitHub(id, os.Getenv("GITHUB_CLIENT_SECRET"), callback("github")))
+	}
+
+	if id := os.Getenv("MICROSOFT_CLIENT_ID"); id != "" {
+		Register(NewMicrosoft(id, os.Getenv("MICROSOFT_CLIENT_SECRET"), callback("microsoft"),
+			os.Getenv("MICROSOFT_TENANT")))
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		name := os.Getenv("OIDC_PROVIDER_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+
+		p, err := NewOIDC(ctx, name, issuer, os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), callback(name))
+		if err != nil {
+			log.Printf("⚠️  OIDC provider %q disabled: %v", name, err)
+		} else {
+			Register(p)
+		}
+	}
+
+	log.Printf("✅ Login providers: %v", Names())
+}
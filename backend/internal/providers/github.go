@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"context"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+type githubProvider struct {
+	oauth2Provider
+}
+
+type githubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func NewGitHub(clientID, clientSecret, redirectURL string) Provider {
+	return &githubProvider{oauth2Provider{
+		name: "github",
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     github.Endpoint,
+		},
+	}}
+}
+
+func (p *githubProvider) Profile(ctx context.Context, token *oauth2.Token, _ string) (*Profile, error) {
+	var user githubUser
+	if err := p.getJSON(ctx, token, "https://api.github.com/user", &user); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		Provider: p.name,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Email:    user.Email,
+		Picture:  user.AvatarURL,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+
+	// The public profile email may be empty or unverified; prefer the
+	// primary verified address from the emails API.
+	var emails []githubEmail
+	if err := p.getJSON(ctx, token, "https://api.github.com/user/emails", &emails); err == nil {
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				profile.Email = e.Email
+				profile.EmailVerified = true
+				break
+			}
+		}
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,20 @@
+package providers
+
+import "context"
+
+const DefaultGoogleIssuer = "https://accounts.google.com"
+
+// NewGoogle creates the Google provider as an OIDC provider. issuerURL is
+// normally DefaultGoogleIssuer; tests point it at a local fake IdP.
+func NewGoogle(ctx context.Context, issuerURL, clientID, clientSecret, redirectURL string) (Provider, error) {
+	if issuerURL == "" {
+		issuerURL = DefaultGoogleIssuer
+	}
+
+	var extraIssuers []string
+	if issuerURL == DefaultGoogleIssuer {
+		extraIssuers = []string{"accounts.google.com"}
+	}
+
+	return NewOIDC(ctx, "google", issuerURL, clientID, clientSecret, redirectURL, extraIssuers...)
+}
@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+type microsoftProvider struct {
+	oauth2Provider
+}
+
+type microsoftUserInfo struct {
+	Sub        string `json:"sub"`
+	Name       string `json:"name"`
+	GivenName  string `json:"given_name"`
+	FamilyName string `json:"family_name"`
+	Email      string `json:"email"`
+}
+
+// NewMicrosoft creates a Microsoft identity platform provider. tenant is
+// "common", "organizations", "consumers" or a directory ID.
+func NewMicrosoft(clientID, clientSecret, redirectURL, tenant string) Provider {
+	if tenant == "" {
+		tenant = "common"
+	}
+	return &microsoftProvider{oauth2Provider{
+		name: "microsoft",
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"openid", "profile", "email", "User.Read"},
+			Endpoint:     microsoft.AzureADEndpoint(tenant),
+		},
+	}}
+}
+
+func (p *microsoftProvider) Profile(ctx context.Context, token *oauth2.Token, _ string) (*Profile, error) {
+	var info microsoftUserInfo
+	if err := p.getJSON(ctx, token, "https://graph.microsoft.com/oidc/userinfo", &info); err != nil {
+		return nil, err
+	}
+
+	name := info.Name
+	if name == "" {
+		name = info.GivenName + " " + info.FamilyName
+	}
+
+	return &Profile{
+		Provider: p.name,
+		Subject:  info.Sub,
+		Name:     name,
+		Email:    info.Email,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oauth-app/backend/internal/oidc"
+	"golang.org/x/oauth2"
+)
+
+type oidcProvider struct {
+	oauth2Provider
+	verifier    *oidc.Verifier
+	userInfoURL string
+}
+
+type oidcUserInfo struct {
+	Sub     string `json:"sub"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Picture string `json:"picture"`
+}
+
+// NewOIDC creates a generic OpenID Connect provider registered under name,
+// resolving its endpoints and signing keys from the issuer's discovery
+// document. extraIssuers lists alternative "iss" values the provider uses.
+func NewOIDC(ctx context.Context, name, issuerURL, clientID, clientSecret, redirectURL string, extraIssuers ...string) (Provider, error) {
+	doc, err := oidc.Discover(ctx, issuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := append([]string{doc.Issuer}, extraIssuers...)
+
+	return &oidcProvider{
+		oauth2Provider: oauth2Provider{
+			name: name,
+			config: &oauth2.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       []string{"openid", "profile", "email"},
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  doc.AuthorizationEndpoint,
+					TokenURL: doc.TokenEndpoint,
+				},
+			},
+		},
+		verifier:    oidc.NewVerifier(clientID, oidc.NewRemoteKeySet(doc.JWKSURI), issuers...),
+		userInfoURL: doc.UserInfoEndpoint,
+	}, nil
+}
+
+// Profile verifies the ID token returned with token and reads the identity
+// from its claims, falling back to the userinfo endpoint for profile fields
+// the provider leaves out of the ID token.
+func (p *oidcProvider) Profile(ctx context.Context, token *oauth2.Token, nonce string) (*Profile, error) {
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%s: token response has no id_token", p.name)
+	}
+
+	claims, err := p.verifier.Verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Name:          claims.Name,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Picture:       claims.Picture,
+		HostedDomain:  claims.HostedDomain,
+	}
+	if claims.AuthTime != nil {
+		profile.AuthTime = claims.AuthTime.Time
+	}
+
+	if (profile.Name == "" || profile.Email == "") && p.userInfoURL != "" {
+		var info oidcUserInfo
+		if err := p.getJSON(ctx, token, p.userInfoURL, &info); err == nil && info.Sub == claims.Subject {
+			if profile.Name == "" {
+				profile.Name = info.Name
+			}
+			if profile.Email == "" {
+				// Not covered by the ID token, so it is not treated as verified
+				profile.Email = info.Email
+			}
+			if profile.Picture == "" {
+				profile.Picture = info.Picture
+			}
+		}
+	}
+
+	return profile, nil
+}
+
+// SupportsReauth reports whether p can force a fresh login (max_age=0) and
+// prove it happened (auth_time in the ID token). Plain OAuth2 providers
+// cannot, so they are not offered for step-up re-authentication.
+func SupportsReauth(p Provider) bool {
+	_, ok := p.(*oidcProvider)
+	return ok
+}
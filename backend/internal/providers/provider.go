@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Profile is the normalized identity returned by every provider.
+type Profile struct {
+	Provider      string
+	Subject       string
+	Name          string
+	Email         string
+	EmailVerified bool
+	Picture       string
+	HostedDomain  string
+	// AuthTime is when the user last actively authenticated at the
+	// provider (OIDC auth_time); zero when the provider does not say.
+	AuthTime time.Time
+}
+
+// Provider is an external identity provider that users can sign in with.
+type Provider interface {
+	Name() string
+	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	// Profile returns the identity behind token. OIDC providers verify the
+	// ID token against nonce; plain OAuth2 providers ignore it.
+	Profile(ctx context.Context, token *oauth2.Token, nonce string) (*Profile, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Provider{}
+)
+
+// Register adds a provider to the registry, replacing any with the same name.
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[p.Name()] = p
+}
+
+// Get looks up a registered provider by name.
+func Get(name string) (Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names returns the registered provider names in sorted order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// oauth2Provider implements the authorization URL and code exchange shared
+// by all providers on top of an oauth2.Config.
+type oauth2Provider struct {
+	name   string
+	config *oauth2.Config
+}
+
+func (p *oauth2Provider) Name() string {
+	return p.name
+}
+
+func (p *oauth2Provider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.config.AuthCodeURL(state, opts...)
+}
+
+func (p *oauth2Provider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.config.Exchange(ctx, code, opts...)
+}
+
+// getJSON performs an authenticated GET and decodes the JSON response.
+func (p *oauth2Provider) getJSON(ctx context.Context, token *oauth2.Token, url string, v any) error {
+	client := p.config.Client(ctx, token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+// personalAccessTokenPrefix makes PATs recognisable (and greppable by
+// secret scanners) and distinguishes them from JWT access tokens.
+const personalAccessTokenPrefix = "pat_"
+
+var (
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	ErrUnknownScope        = errors.New("unknown scope")
+)
+
+var accessTokenSelectFields = `id, user_id, name, token_prefix, scopes, created_at, expires_at, last_used_at`
+
+func scanAccessToken(row interface{ Scan(dest ...any) error }) (*models.PersonalAccessToken, error) {
+	var t models.PersonalAccessToken
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenPrefix, &t.Scopes, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
+
+// CreateAccessToken creates a token and returns it with its secret, which
+// is only ever available at creation time.
+func CreateAccessToken(ctx context.Context, userID string, req models.CreateAccessTokenRequest) (*models.PersonalAccessToken, string, error) {
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.AccessTokenScopes, scope) {
+			return nil, "", fmt.Errorf("%w %q", ErrUnknownScope, scope)
+		}
+	}
+
+	random, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := personalAccessTokenPrefix + random
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &t
+	}
+
+	row := database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING %s`, accessTokenSelectFields),
+		userID, req.Name, hashToken(secret), secret[:len(personalAccessTokenPrefix)+6], req.Scopes, expiresAt)
+	token, err := scanAccessToken(row)
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+func GetAccessTokens(ctx context.Context, userID string) ([]models.PersonalAccessToken, error) {
+	rows, err := database.Pool.Query(ctx,
+		fmt.Sprintf(`SELECT %s FROM personal_access_tokens
+		 WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`, accessTokenSelectFields), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.PersonalAccessToken
+	for rows.Next() {
+		t, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, nil
+}
+
+func RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	if !isUUID(tokenID) {
+		return ErrAccessTokenNotFound
+	}
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE personal_access_tokens SET revoked_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// ValidateAccessToken looks up an active token by its secret and records
+// that it was used.
+func ValidateAccessToken(ctx context.Context, secret string) (*models.PersonalAccessToken, error) {
+	row := database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`UPDATE personal_access_tokens SET last_used_at = NOW()
+		 WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		 RETURNING %s`, accessTokenSelectFields), hashToken(secret))
+	token, err := scanAccessToken(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAccessTokenNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
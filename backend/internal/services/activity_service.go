@@ -8,14 +8,21 @@ import (
 )
 
 func LogActivity(ctx context.Context, userID, action string) error {
+	return LogActivityBy(ctx, userID, "", action)
+}
+
+// LogActivityBy records an action on userID's account performed by the
+// administrator impersonatorID while signed in as them ("" for the user).
+func LogActivityBy(ctx context.Context, userID, impersonatorID, action string) error {
 	_, err := database.Pool.Exec(ctx,
-		`INSERT INTO activity_logs (user_id, action) VALUES ($1, $2)`, userID, action)
+		`INSERT INTO activity_logs (user_id, action, impersonator_id) VALUES ($1, $2, NULLIF($3, '')::uuid)`,
+		userID, action, impersonatorID)
 	return err
 }
 
 func GetRecentActivity(ctx context.Context, userID string, limit int) ([]models.ActivityLog, error) {
 	rows, err := database.Pool.Query(ctx,
-		`SELECT id, user_id, action, created_at FROM activity_logs
+		`SELECT id, user_id, action, created_at, impersonator_id FROM activity_logs
 		 WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, userID, limit)
 	if err != nil {
 		return nil, err
@@ -25,7 +32,7 @@ func GetRecentActivity(ctx context.Context, userID string, limit int) ([]models.
 	var activities []models.ActivityLog
 	for rows.Next() {
 		var a models.ActivityLog
-		if err := rows.Scan(&a.ID, &a.UserID, &a.Action, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Action, &a.CreatedAt, &a.ImpersonatorID); err != nil {
 			return nil, err
 		}
 		activities = append(activities, a)
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+// SearchUsers finds users whose name, email or username contains query,
+// optionally only those with role. It also returns the total match count.
+func SearchUsers(ctx context.Context, query, role string, limit, offset int) ([]models.User, int, error) {
+	where := "WHERE ($1 = '' OR name ILIKE $1 OR email ILIKE $1 OR username ILIKE $1) AND ($2 = '' OR role = $2)"
+	pattern := ""
+	if query != "" {
+		// Match the search text literally
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+		pattern = "%" + escaped + "%"
+	}
+
+	var total int
+	if err := database.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM users `+where, pattern, role).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := database.Pool.Query(ctx,
+		fmt.Sprintf(`SELECT %s FROM users %s ORDER BY created_at DESC LIMIT $3 OFFSET $4`, userSelectFields, where),
+		pattern, role, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
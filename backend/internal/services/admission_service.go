@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
+)
+
+var ErrSignupNotAllowed = errors.New("sign-up is not allowed")
+
+// AdmissionPolicy decides who may create an account. Existing users can
+// always sign in.
+type AdmissionPolicy struct {
+	// Google Workspace domains (hd claim) whose members may sign up
+	AllowedDomains []string
+	// Email patterns with * wildcards, e.g. "*@example.com"
+	AllowedEmails []string
+	DeniedEmails  []string
+	// Only people holding an invitation may sign up
+	InviteOnly bool
+}
+
+// Admission is the policy loaded from the environment by InitAuth.
+var Admission AdmissionPolicy
+
+// LoadAdmissionPolicy reads SIGNUP_ALLOWED_DOMAINS, SIGNUP_EMAIL_ALLOWLIST,
+// SIGNUP_EMAIL_DENYLIST (comma-separated) and SIGNUP_INVITE_ONLY.
+func LoadAdmissionPolicy() AdmissionPolicy {
+	return AdmissionPolicy{
+		AllowedDomains: splitList(os.Getenv("SIGNUP_ALLOWED_DOMAINS")),
+		AllowedEmails:  splitList(os.Getenv("SIGNUP_EMAIL_ALLOWLIST")),
+		DeniedEmails:   splitList(os.Getenv("SIGNUP_EMAIL_DENYLIST")),
+		InviteOnly:     os.Getenv("SIGNUP_INVITE_ONLY") == "true",
+	}
+}
+
+// Admits reports whether profile may create an account. The denylist always
+// applies; an invitation stands in for the domain and email allowlists.
+func (p AdmissionPolicy) Admits(profile *providers.Profile, invited bool) bool {
+	email := strings.ToLower(profile.Email)
+	if matchesAny(email, p.DeniedEmails) {
+		return false
+	}
+	if invited {
+		return true
+	}
+	if p.InviteOnly {
+		return false
+	}
+	if len(p.AllowedDomains) == 0 && len(p.AllowedEmails) == 0 {
+		return true
+	}
+
+	// hd names a Google Workspace domain; another IdP may emit it or let
+	// users set it, so it is only trusted from Google
+	if profile.Provider == "google" && profile.HostedDomain != "" &&
+		slices.Contains(p.AllowedDomains, strings.ToLower(profile.HostedDomain)) {
+		return true
+	}
+	// An unverified address could be anyone's
+	return profile.EmailVerified && matchesAny(email, p.AllowedEmails)
+}
+
+// SignUp creates an account for a new identity if the admission policy
+// allows it. A non-empty invitationID (from the signed OAuth state) must
+// be a usable invitation; the sign-up redeems it.
+func SignUp(ctx context.Context, profile *providers.Profile, invitationID string) (*models.User, error) {
+	var invitation *models.Invitation
+	if invitationID != "" {
+		var err error
+		invitation, err = findUsableInvitation(ctx, invitationID, profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !Admission.Admits(profile, invitation != nil) {
+		return nil, ErrSignupNotAllowed
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := createUser(ctx, tx, profile.Provider, profile.Subject, profile.Name, profile.Email, profile.Picture)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first admin comes from ADMIN_EMAILS; later ones are appointed
+	if profile.EmailVerified && isBootstrapAdmin(profile.Email) {
+		tag, err := tx.Exec(ctx, `UPDATE users SET role = 'admin' WHERE id = $1 AND `+noAdmin, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 1 {
+			user.Role = models.RoleAdmin
+		}
+	}
+
+	if invitation != nil {
+		if err := redeemInvitation(ctx, tx, invitation.ID, user.ID); err != nil {
+			return nil, err
+		}
+		user.InvitedBy = invitation.CreatedBy
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func matchesAny(email string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, email); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList parses a comma-separated setting into lower-case entries.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
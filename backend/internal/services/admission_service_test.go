@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/oauth-app/backend/internal/providers"
+)
+
+func TestAdmissionPolicyAdmits(t *testing.T) {
+	workspace := &providers.Profile{Provider: "google", Email: "ann@corp.example", EmailVerified: true, HostedDomain: "corp.example"}
+	otherIdP := &providers.Profile{Provider: "okta", Email: "eve@corp.example", EmailVerified: true, HostedDomain: "corp.example"}
+	partner := &providers.Profile{Email: "bob@partner.example", EmailVerified: true}
+	unverified := &providers.Profile{Email: "eve@partner.example"}
+	blocked := &providers.Profile{Provider: "google", Email: "Mallory@Corp.example", EmailVerified: true, HostedDomain: "corp.example"}
+
+	restricted := AdmissionPolicy{
+		AllowedDomains: []string{"corp.example"},
+		AllowedEmails:  []string{"*@partner.example"},
+		DeniedEmails:   []string{"mallory@*"},
+	}
+
+	cases := []struct {
+		name    string
+		policy  AdmissionPolicy
+		profile *providers.Profile
+		invited bool
+		want    bool
+	}{
+		{"open policy", AdmissionPolicy{}, partner, false, true},
+		{"allowed hd domain", restricted, workspace, false, true},
+		{"hd from another provider", restricted, otherIdP, false, false},
+		{"allowlisted email", restricted, partner, false, true},
+		{"unverified email", restricted, unverified, false, false},
+		{"denylist wins over domain", restricted, blocked, false, false},
+		{"denylist wins over invitation", restricted, blocked, true, false},
+		{"invitation bypasses allowlists", restricted, &providers.Profile{Email: "x@other.example"}, true, true},
+		{"invite only without invitation", AdmissionPolicy{InviteOnly: true}, workspace, false, false},
+		{"invite only with invitation", AdmissionPolicy{InviteOnly: true}, workspace, true, true},
+	}
+	for _, tc := range cases {
+		if got := tc.policy.Admits(tc.profile, tc.invited); got != tc.want {
+			t.Errorf("%s: Admits = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" Corp.example, ,partner.example ")
+	if len(got) != 2 || got[0] != "corp.example" || got[1] != "partner.example" {
+		t.Errorf("splitList = %q", got)
+	}
+}
@@ -1,65 +1,164 @@
 package services
 
 import (
+	"crypto/rand"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
 )
 
-var GoogleOAuthConfig *oauth2.Config
-var JWTSecret []byte
+// ReauthMaxAge is how recently the user must have authenticated to use
+// endpoints guarded by RequireRecentAuth (REAUTH_MAX_AGE, e.g. "10m").
+var ReauthMaxAge = 5 * time.Minute
 
-func InitAuth() {
-	GoogleOAuthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("BACKEND_URL") + "/auth/google/callback",
-		Scopes:       []string{"openid", "profile", "email"},
-		Endpoint:     google.Endpoint,
+const (
+	// tokenIssuer is the iss of the tokens this backend issues for itself
+	tokenIssuer = "oauth-app"
+	// sessionTokenAudience is the aud of session access tokens. The other
+	// tokens signed by the keyring (MFA, restore, OAuth access and ID
+	// tokens, ...) never carry it, so they are not accepted as sessions.
+	sessionTokenAudience = "session"
+)
+
+// HMACSecret signs short-lived server-side values such as OAuth state.
+// Access tokens are signed with the asymmetric keyring instead.
+var HMACSecret []byte
+
+func InitAuth() error {
+	providers.InitFromEnv(os.Getenv("BACKEND_URL"))
+
+	HMACSecret = []byte(os.Getenv("JWT_SECRET"))
+	if len(HMACSecret) == 0 {
+		log.Println("⚠️  JWT_SECRET is not set; using a random secret (in-flight logins will not survive a restart)")
+		HMACSecret = make([]byte, 32)
+		if _, err := rand.Read(HMACSecret); err != nil {
+			return err
+		}
 	}
-	JWTSecret = []byte(os.Getenv("JWT_SECRET"))
+
+	if v := os.Getenv("REAUTH_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid REAUTH_MAX_AGE: %w", err)
+		}
+		ReauthMaxAge = d
+	}
+
+	Admission = LoadAdmissionPolicy()
+
+	if err := InitWebAuthn(); err != nil {
+		return err
+	}
+
+	keyDir := os.Getenv("JWT_KEY_DIR")
+	if keyDir == "" {
+		keyDir = "keys"
+	}
+	return InitKeyring(keyDir, os.Getenv("JWT_SIGNING_ALG"), os.Getenv("JWT_ACTIVE_KID"))
 }
 
 type JWTClaims struct {
 	UserID   string `json:"user_id"`
 	Email    string `json:"email"`
 	Username string `json:"username"`
+	// Role is informational for clients; RequirePermission checks the
+	// user's current role
+	Role string `json:"role"`
+	// AuthTime is when the user last authenticated in this session, used
+	// for step-up checks (see RequireRecentAuth).
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Act identifies the administrator behind an impersonation session
+	// (RFC 8693 actor claim)
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID, email, username string) (string, error) {
+type ActorClaim struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+}
+
+// GenerateJWT issues a token for the given session; the session ID is the jti.
+func GenerateJWT(user *models.User, sessionID string, authTime time.Time) (string, error) {
 	claims := JWTClaims{
-		UserID:   userID,
-		Email:    email,
-		Username: username,
+		UserID:   user.ID,
+		Email:    user.Email,
+		Username: user.Username,
+		Role:     user.Role,
+		AuthTime: jwt.NewNumericDate(authTime),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "oauth-app",
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{sessionTokenAudience},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(JWTSecret)
+	return signToken(claims)
 }
 
-func ValidateJWT(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return JWTSecret, nil
-	})
+// GenerateImpersonationJWT issues a token for an impersonation session:
+// it acts as user on behalf of actor and has no auth_time, so it never
+// passes RequireRecentAuth.
+func GenerateImpersonationJWT(user, actor *models.User, sessionID string) (string, error) {
+	claims := JWTClaims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Username: user.Username,
+		Role:     user.Role,
+		Act:      &ActorClaim{Subject: actor.ID, Email: actor.Email},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ImpersonationTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{sessionTokenAudience},
+		},
+	}
+
+	return signToken(claims)
+}
+
+// signUserToken issues a short-lived token that identifies userID to a
+// single endpoint (audience) without a session, e.g. account restore.
+func signUserToken(userID, audience string, ttl time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    tokenIssuer,
+	}
+	return signToken(claims)
+}
+
+// parseUserToken returns the user ID of a token from signUserToken.
+func parseUserToken(tokenString, audience string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey, jwt.WithAudience(audience))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid || claims.Subject == "" {
+		return "", fmt.Errorf("invalid token")
+	}
+	return claims.Subject, nil
+}
 
+func ValidateJWT(tokenString string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, verificationKey,
+		jwt.WithAudience(sessionTokenAudience), jwt.WithIssuer(tokenIssuer))
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid && claims.UserID != "" {
 		return claims, nil
 	}
 
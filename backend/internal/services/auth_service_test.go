@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+func TestValidateJWTRejectsOtherTokens(t *testing.T) {
+	if err := InitKeyring(t.TempDir(), "ES256", ""); err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	user := &models.User{ID: "user-1", Email: "ada@example.com"}
+
+	session, err := GenerateJWT(user, "session-1", time.Now())
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	claims, err := ValidateJWT(session)
+	if err != nil {
+		t.Fatalf("session token rejected: %v", err)
+	}
+	if claims.UserID != user.ID || claims.ID != "session-1" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	mfa, err := GenerateMFAToken(user.ID, "session-1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore, err := GenerateRestoreToken(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notice, err := GenerateSuspensionNoticeToken(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauth, err := newOAuthTokenResponse(user, "client-1", "grant-1", []string{models.OAuthScopeOpenID}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, err := GenerateIDToken(user, "client-1", "", time.Now(), []string{models.OAuthScopeOpenID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A keyring token with a user_id but no session audience
+	unscoped, err := signToken(JWTClaims{UserID: user.ID, RegisteredClaims: jwt.RegisteredClaims{
+		ID:        "session-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		Issuer:    tokenIssuer,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"mfa":               mfa,
+		"restore":           restore,
+		"suspension notice": notice,
+		"oauth access":      oauth.AccessToken,
+		"id token":          idToken,
+		"unscoped":          unscoped,
+	} {
+		if _, err := ValidateJWT(token); err == nil {
+			t.Errorf("%s token accepted as a session token", name)
+		}
+	}
+}
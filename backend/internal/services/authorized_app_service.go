@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+var ErrAuthorizedAppNotFound = errors.New("app not found")
+
+// GetAuthorizedApps lists the apps a user has allowed, with the scopes
+// they were granted and when the user's tokens were last used, most
+// recently used first.
+func GetAuthorizedApps(ctx context.Context, userID string) ([]models.AuthorizedApp, error) {
+	rows, err := database.Pool.Query(ctx,
+		`SELECT c.id, c.name, cs.scopes, cs.created_at, cs.updated_at,
+		        (SELECT MAX(g.last_used_at) FROM oauth_grants g
+		         WHERE g.user_id = cs.user_id AND g.client_id = cs.client_id) AS last_used_at
+		 FROM oauth_consents cs JOIN oauth_clients c ON c.id = cs.client_id
+		 WHERE cs.user_id = $1 AND c.revoked_at IS NULL
+		 ORDER BY last_used_at DESC NULLS LAST, cs.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []models.AuthorizedApp
+	for rows.Next() {
+		var a models.AuthorizedApp
+		if err := rows.Scan(&a.ClientID, &a.Name, &a.Scopes, &a.GrantedAt, &a.UpdatedAt, &a.LastUsedAt); err != nil {
+			return nil, err
+		}
+		apps = append(apps, a)
+	}
+	return apps, rows.Err()
+}
+
+// RevokeAuthorizedApp withdraws the user's consent for an app and revokes
+// every token issued to it for the user, including codes not yet
+// redeemed. It returns the app's name.
+func RevokeAuthorizedApp(ctx context.Context, userID, clientID string) (string, error) {
+	if !isUUID(clientID) {
+		return "", ErrAuthorizedAppNotFound
+	}
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var name string
+	err = tx.QueryRow(ctx,
+		`WITH deleted AS (
+		     DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2 RETURNING client_id
+		 )
+		 SELECT c.name FROM deleted JOIN oauth_clients c ON c.id = deleted.client_id`,
+		userID, clientID).Scan(&name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrAuthorizedAppNotFound
+		}
+		return "", err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE oauth_grants SET revoked_at = NOW()
+		 WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL`, userID, clientID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE oauth_authorization_codes SET used_at = NOW()
+		 WHERE user_id = $1 AND client_id = $2 AND used_at IS NULL`, userID, clientID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE oauth_device_codes SET used_at = NOW()
+		 WHERE user_id = $1 AND client_id = $2 AND used_at IS NULL`, userID, clientID); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return name, nil
+}
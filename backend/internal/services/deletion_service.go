@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/oauth-app/backend/internal/database"
+)
+
+const (
+	// RestoreTokenCookie lets a user who signs in to an account pending
+	// deletion restore it without a session.
+	RestoreTokenCookie = "restore_token"
+	RestoreTokenTTL    = 15 * time.Minute
+
+	restoreTokenAudience = "restore"
+)
+
+var ErrAccountNotPendingDeletion = errors.New("account is not pending deletion")
+
+// AccountDeletionGrace returns how long a deleted account can be restored
+// (ACCOUNT_DELETION_GRACE_DAYS, default 30).
+func AccountDeletionGrace() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// SoftDeleteUser hides the account and schedules it for purging. All its
+// sessions and personal access tokens are revoked immediately, and its data
+// exports are deleted so no download link keeps working.
+func SoftDeleteUser(ctx context.Context, userID string) (time.Time, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var purgeAfter time.Time
+	err = tx.QueryRow(ctx,
+		`UPDATE users SET deleted_at = NOW(), purge_after = $2, updated_at = NOW()
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING purge_after`, userID, time.Now().Add(AccountDeletionGrace())).Scan(&purgeAfter)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return time.Time{}, err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	rows, err := tx.Query(ctx, `DELETE FROM data_exports WHERE user_id = $1 RETURNING file_path`, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	exports, err := collectExportPaths(rows)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return time.Time{}, err
+	}
+	removeExportFiles(exports)
+	return purgeAfter, nil
+}
+
+// RestoreUser cancels a pending deletion.
+func RestoreUser(ctx context.Context, userID string) error {
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE users SET deleted_at = NULL, purge_after = NULL, updated_at = NOW()
+		 WHERE id = $1 AND deleted_at IS NOT NULL`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAccountNotPendingDeletion
+	}
+	return nil
+}
+
+// PurgeDeletedUsers hard-deletes accounts whose grace period is over and
+// leaves a tombstone (user ID, hashed email, dates) for each one. Their
+// export archives are removed from disk first; the rows would only cascade.
+func PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	rows, err := database.Pool.Query(ctx,
+		`DELETE FROM data_exports WHERE user_id IN (
+		     SELECT id FROM users WHERE deleted_at IS NOT NULL AND purge_after <= NOW())
+		 RETURNING file_path`)
+	if err != nil {
+		return 0, err
+	}
+	exports, err := collectExportPaths(rows)
+	if err != nil {
+		return 0, err
+	}
+	removeExportFiles(exports)
+
+	tag, err := database.Pool.Exec(ctx,
+		`WITH purged AS (
+		     DELETE FROM users WHERE deleted_at IS NOT NULL AND purge_after <= NOW()
+		     RETURNING id, email, created_at, deleted_at
+		 )
+		 INSERT INTO account_tombstones (user_id, email_hash, created_at, deleted_at)
+		 SELECT id, encode(sha256(convert_to(lower(email), 'UTF8')), 'hex'), created_at, deleted_at FROM purged`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// StartAccountPurger runs PurgeDeletedUsers every interval until ctx is done.
+func StartAccountPurger(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, interval, func() {
+		n, err := PurgeDeletedUsers(ctx)
+		if err != nil {
+			log.Printf("⚠️  Account purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("🗑️  Purged %d deleted account(s)", n)
+		}
+	})
+}
+
+// runPeriodically calls fn now and then every interval in the background
+// until ctx is done.
+func runPeriodically(ctx context.Context, interval time.Duration, fn func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			fn()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func GenerateRestoreToken(userID string) (string, error) {
+	return signUserToken(userID, restoreTokenAudience, RestoreTokenTTL)
+}
+
+// ValidateRestoreToken returns the user ID the token was issued for.
+func ValidateRestoreToken(tokenString string) (string, error) {
+	return parseUserToken(tokenString, restoreTokenAudience)
+}
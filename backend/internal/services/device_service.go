@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+const (
+	DeviceCodeTTL = 10 * time.Minute
+	// deviceCodeInterval is the initial minimum time between polls; each
+	// slow_down adds deviceCodeSlowDown (RFC 8628 §3.5).
+	deviceCodeInterval = 5 * time.Second
+	deviceCodeSlowDown = 5 * time.Second
+
+	// Consonants only: no vowels to spell words, no digits to confuse
+	// with letters (RFC 8628 §6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// Device flow errors; handlers map them to the RFC 8628 §3.5 error codes.
+var (
+	ErrDeviceCodeNotFound   = errors.New("invalid or expired user code")
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("polling too frequently")
+	ErrExpiredToken         = errors.New("device code expired")
+	ErrAccessDenied         = errors.New("the user denied the request")
+)
+
+// RequestDeviceCode starts a device authorization for client. The
+// verification URIs are left for the caller to fill in.
+func RequestDeviceCode(ctx context.Context, client *models.OAuthClient, scope string) (*models.DeviceAuthorizationResponse, error) {
+	scopes, err := parseOAuthScopes(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retry the rare clash with a live user code
+	for attempt := 0; ; attempt++ {
+		userCode, err := generateUserCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = database.Pool.Exec(ctx,
+			`INSERT INTO oauth_device_codes (device_code_hash, user_code, client_id, scopes, interval_seconds, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			hashToken(deviceCode), userCode, client.ID, scopes,
+			int(deviceCodeInterval.Seconds()), time.Now().Add(DeviceCodeTTL))
+		if isUniqueViolation(err, "oauth_device_codes_user_code_key") && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Opportunistic cleanup; expired codes are kept a while so polling
+		// devices get expired_token rather than invalid_grant
+		_, _ = database.Pool.Exec(ctx,
+			`DELETE FROM oauth_device_codes WHERE expires_at < NOW() - INTERVAL '1 day'`)
+
+		return &models.DeviceAuthorizationResponse{
+			DeviceCode: deviceCode,
+			UserCode:   FormatUserCode(userCode),
+			ExpiresIn:  int(DeviceCodeTTL.Seconds()),
+			Interval:   int(deviceCodeInterval.Seconds()),
+		}, nil
+	}
+}
+
+// GetDeviceConsent describes a pending device authorization to the user
+// who entered its user code.
+func GetDeviceConsent(ctx context.Context, userID, userCode string) (*models.ConsentScreen, error) {
+	var (
+		clientID string
+		scopes   []string
+	)
+	err := database.Pool.QueryRow(ctx,
+		`SELECT client_id, scopes FROM oauth_device_codes
+		 WHERE user_code = $1 AND status = 'pending' AND expires_at > NOW()`,
+		normalizeUserCode(userCode)).Scan(&clientID, &scopes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeviceCodeNotFound
+		}
+		return nil, err
+	}
+
+	client, err := FindOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrOAuthClientNotFound) {
+			return nil, ErrDeviceCodeNotFound
+		}
+		return nil, err
+	}
+
+	screen, err := GetConsentScreen(ctx, userID, client, scopes)
+	if err != nil {
+		return nil, err
+	}
+	// The user must confirm they started this on their device, even for
+	// an app they already allowed
+	screen.ConsentRequired = true
+	return screen, nil
+}
+
+// DecideDeviceAuthorization records the user's answer for a user code and
+// returns the client it was for. Approving also records the consent.
+func DecideDeviceAuthorization(ctx context.Context, userID, userCode string, approved bool, authTime time.Time) (*models.OAuthClient, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	status := "denied"
+	if approved {
+		status = "approved"
+	}
+
+	var (
+		clientID string
+		scopes   []string
+	)
+	err = tx.QueryRow(ctx,
+		`UPDATE oauth_device_codes SET status = $2, user_id = $3, authenticated_at = $4
+		 WHERE user_code = $1 AND status = 'pending' AND expires_at > NOW()
+		 RETURNING client_id, scopes`,
+		normalizeUserCode(userCode), status, userID, authTime).Scan(&clientID, &scopes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeviceCodeNotFound
+		}
+		return nil, err
+	}
+
+	client, err := FindOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrOAuthClientNotFound) {
+			return nil, ErrDeviceCodeNotFound
+		}
+		return nil, err
+	}
+
+	if approved {
+		if err := recordConsent(ctx, tx, userID, clientID, scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// ExchangeDeviceCode is polled by the device until the user has answered.
+// It returns ErrAuthorizationPending while waiting and ErrSlowDown (with
+// a longer interval from then on) when polled too often. A device code
+// yields tokens once.
+func ExchangeDeviceCode(ctx context.Context, client *models.OAuthClient, deviceCode string) (*models.OAuthTokenResponse, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		codeID, codeClientID, status string
+		userID                       *string
+		scopes                       []string
+		authTime, lastPolledAt       *time.Time
+		usedAt                       *time.Time
+		interval                     int
+		expiresAt                    time.Time
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT id, client_id, status, user_id, scopes, authenticated_at, last_polled_at, used_at, interval_seconds, expires_at
+		 FROM oauth_device_codes WHERE device_code_hash = $1
+		 FOR UPDATE`, hashToken(deviceCode)).
+		Scan(&codeID, &codeClientID, &status, &userID, &scopes, &authTime, &lastPolledAt, &usedAt, &interval, &expiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	if codeClientID != client.ID || usedAt != nil {
+		return nil, ErrInvalidGrant
+	}
+	if time.Now().After(expiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	if lastPolledAt != nil && time.Since(*lastPolledAt) < time.Duration(interval)*time.Second {
+		if _, err := tx.Exec(ctx,
+			`UPDATE oauth_device_codes SET interval_seconds = interval_seconds + $2, last_polled_at = NOW()
+			 WHERE id = $1`, codeID, int(deviceCodeSlowDown.Seconds())); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrSlowDown
+	}
+
+	if status == "pending" {
+		if _, err := tx.Exec(ctx,
+			`UPDATE oauth_device_codes SET last_polled_at = NOW() WHERE id = $1`, codeID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrAuthorizationPending
+	}
+
+	// Answered either way: the device code is used up
+	if _, err := tx.Exec(ctx,
+		`UPDATE oauth_device_codes SET used_at = NOW(), last_polled_at = NOW() WHERE id = $1`, codeID); err != nil {
+		return nil, err
+	}
+	if status != "approved" || userID == nil || authTime == nil {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrAccessDenied
+	}
+
+	user, err := findAuthorizableUser(ctx, *userID)
+	if err != nil {
+		return nil, err
+	}
+	grantID, refresh, err := createGrant(ctx, tx, client.ID, user.ID, scopes, *authTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return newGrantTokenResponse(user, client.ID, grantID, scopes, refresh, "", *authTime)
+}
+
+// generateUserCode returns userCodeLength random letters of
+// userCodeAlphabet, stored without formatting.
+func generateUserCode() (string, error) {
+	code := make([]byte, 0, userCodeLength)
+	b := make([]byte, 1)
+	for len(code) < userCodeLength {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		// Reject the tail of the byte range to keep letters uniform
+		if int(b[0]) >= 256-256%len(userCodeAlphabet) {
+			continue
+		}
+		code = append(code, userCodeAlphabet[int(b[0])%len(userCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// FormatUserCode splits a user code like "BDFGHJKL" into "BDFG-HJKL".
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// normalizeUserCode accepts user codes typed in any case, with or without
+// the dash.
+func normalizeUserCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
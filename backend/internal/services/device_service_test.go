@@ -0,0 +1,34 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateUserCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := generateUserCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != userCodeLength {
+			t.Fatalf("generateUserCode() = %q, want %d characters", code, userCodeLength)
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(userCodeAlphabet, r) {
+				t.Fatalf("generateUserCode() = %q, contains %q", code, r)
+			}
+		}
+	}
+}
+
+func TestUserCodeRoundTrip(t *testing.T) {
+	if got := FormatUserCode("BDFGHJKL"); got != "BDFG-HJKL" {
+		t.Errorf("FormatUserCode() = %q", got)
+	}
+	for _, typed := range []string{"BDFG-HJKL", "bdfg-hjkl", "bdfghjkl", " BDFG HJKL "} {
+		if got := normalizeUserCode(typed); got != "BDFGHJKL" {
+			t.Errorf("normalizeUserCode(%q) = %q", typed, got)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+const (
+	// ExportRetention is how long a finished archive is kept on disk.
+	ExportRetention = 7 * 24 * time.Hour
+	// ExportLinkTTL is how long a signed download link stays valid.
+	ExportLinkTTL = time.Hour
+
+	maxAvatarBytes = 5 << 20
+)
+
+var (
+	ErrExportNotFound    = errors.New("export not found")
+	ErrExportNotReady    = errors.New("export is not ready")
+	ErrInvalidExportLink = errors.New("invalid or expired download link")
+)
+
+// exportTable is one table of the archive, written as <name>.json and <name>.csv.
+// Secrets (token hashes, TOTP secrets, passkey public keys, recovery code
+// hashes) are deliberately not selected.
+type exportTable struct {
+	name        string
+	description string
+	query       string
+}
+
+var exportTables = []exportTable{
+	{"profile", "Your account and profile",
+		`SELECT id::text, name, email, image, username, bio, phone, location, is_public,
+		        login_count, last_login_at, created_at, updated_at, invited_by::text
+		 FROM users WHERE id = $1`},
+	{"identities", "Sign-in providers linked to your account",
+		`SELECT provider, subject, email, created_at, last_login_at
+		 FROM user_identities WHERE user_id = $1 ORDER BY created_at`},
+	{"activity_logs", "Everything recorded in your activity log",
+		`SELECT id::text, action, created_at
+		 FROM activity_logs WHERE user_id = $1 ORDER BY created_at`},
+	// Viewer IPs are other people's data and are left out
+	{"profile_views", "When your public profile was viewed",
+		`SELECT id::text, viewed_at
+		 FROM profile_views WHERE user_id = $1 ORDER BY viewed_at`},
+	{"sessions", "Devices that signed in to your account",
+		`SELECT id::text, device, ip_address, user_agent, created_at, last_seen_at, expires_at, revoked_at
+		 FROM sessions WHERE user_id = $1 AND NOT mfa_pending ORDER BY created_at`},
+	{"access_tokens", "Personal access tokens (secrets are never stored)",
+		`SELECT id::text, name, token_prefix, array_to_string(scopes, ' ') AS scopes,
+		        created_at, expires_at, last_used_at, revoked_at
+		 FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at`},
+	{"passkeys", "Registered passkeys",
+		`SELECT id::text, name, created_at, last_used_at
+		 FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`},
+	{"two_factor", "Authenticator app enrollment",
+		`SELECT created_at, enabled_at FROM user_totp WHERE user_id = $1`},
+	{"profile_sync", "How each profile field is refreshed from your login provider",
+		`SELECT field, policy, edited_at
+		 FROM profile_sync_settings WHERE user_id = $1 ORDER BY field`},
+	// Which staff member acted is left out
+	{"suspensions", "Suspensions and bans of your account",
+		`SELECT id::text, kind, reason, created_at, expires_at, lifted_at
+		 FROM user_suspensions WHERE user_id = $1 ORDER BY created_at`},
+	{"invitations", "Invitations you created",
+		`SELECT id::text, email, max_uses, use_count, created_at, expires_at, revoked_at
+		 FROM invitations WHERE created_by = $1 ORDER BY created_at`},
+	{"invitation_redemptions", "The invitation you signed up with",
+		`SELECT invitation_id::text, redeemed_at
+		 FROM invitation_redemptions WHERE user_id = $1 ORDER BY redeemed_at`},
+	{"oauth_consents", "Apps you allowed to sign you in and the scopes you granted",
+		`SELECT c.client_id::text, o.name AS client_name, array_to_string(c.scopes, ' ') AS scopes,
+		        c.created_at, c.updated_at
+		 FROM oauth_consents c JOIN oauth_clients o ON o.id = c.client_id
+		 WHERE c.user_id = $1 ORDER BY c.created_at`},
+	{"oauth_grants", "Access those apps have had to your account",
+		`SELECT g.id::text, g.client_id::text, o.name AS client_name, array_to_string(g.scopes, ' ') AS scopes,
+		        g.authenticated_at, g.created_at, g.last_used_at, g.revoked_at
+		 FROM oauth_grants g JOIN oauth_clients o ON o.id = g.client_id
+		 WHERE g.user_id = $1 ORDER BY g.created_at`},
+}
+
+// exportDir is where archives are written (EXPORT_DIR, default ./exports).
+func exportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "exports"
+}
+
+const exportSelectFields = `id, user_id, status, file_path, size_bytes, error, created_at, completed_at, expires_at`
+
+func scanExport(row pgx.Row) (*models.DataExport, error) {
+	var e models.DataExport
+	err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.FilePath, &e.SizeBytes, &e.Error,
+		&e.CreatedAt, &e.CompletedAt, &e.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// RequestDataExport queues a new archive and builds it in the background.
+// While an earlier request is still being built, that one is returned.
+func RequestDataExport(ctx context.Context, userID string) (*models.DataExport, error) {
+	existing, err := scanExport(database.Pool.QueryRow(ctx,
+		`SELECT `+exportSelectFields+` FROM data_exports
+		 WHERE user_id = $1 AND status IN ('pending', 'running')
+		 ORDER BY created_at DESC LIMIT 1`, userID))
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	export, err := scanExport(database.Pool.QueryRow(ctx,
+		`INSERT INTO data_exports (user_id, expires_at) VALUES ($1, $2)
+		 RETURNING `+exportSelectFields, userID, time.Now().Add(ExportRetention)))
+	if err != nil {
+		return nil, err
+	}
+
+	go buildDataExport(context.Background(), export.ID, userID)
+	return export, nil
+}
+
+// GetDataExport returns one of the user's exports.
+func GetDataExport(ctx context.Context, userID, exportID string) (*models.DataExport, error) {
+	if !isUUID(exportID) {
+		return nil, ErrExportNotFound
+	}
+	export, err := scanExport(database.Pool.QueryRow(ctx,
+		`SELECT `+exportSelectFields+` FROM data_exports WHERE id = $1 AND user_id = $2`,
+		exportID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+	return export, nil
+}
+
+// GetExportForDownload checks a signed link and returns the finished export.
+func GetExportForDownload(ctx context.Context, exportID string, expires int64, sig string) (*models.DataExport, error) {
+	if !VerifyExportLink(exportID, expires, sig) {
+		return nil, ErrInvalidExportLink
+	}
+
+	export, err := scanExport(database.Pool.QueryRow(ctx,
+		`SELECT `+exportSelectFields+` FROM data_exports WHERE id = $1 AND expires_at > NOW()`, exportID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+	if export.Status != models.ExportStatusReady {
+		return nil, ErrExportNotReady
+	}
+	return export, nil
+}
+
+// SignExportLink returns the signature for a download link of exportID
+// that is valid until expires (unix seconds).
+func SignExportLink(exportID string, expires int64) string {
+	mac := hmac.New(sha256.New, HMACSecret)
+	mac.Write([]byte("data-export:"))
+	mac.Write([]byte(exportID + ":" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyExportLink(exportID string, expires int64, sig string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(SignExportLink(exportID, expires)))
+}
+
+// PurgeExpiredExports deletes archives past their retention and fails jobs
+// that were interrupted (e.g. by a restart) before they finished.
+func PurgeExpiredExports(ctx context.Context) (int, error) {
+	if _, err := database.Pool.Exec(ctx,
+		`UPDATE data_exports SET status = 'failed', error = 'export was interrupted', completed_at = NOW()
+		 WHERE status IN ('pending', 'running') AND created_at < NOW() - INTERVAL '1 hour'`); err != nil {
+		return 0, err
+	}
+
+	rows, err := database.Pool.Query(ctx,
+		`DELETE FROM data_exports WHERE expires_at <= NOW() RETURNING file_path`)
+	if err != nil {
+		return 0, err
+	}
+	paths, err := collectExportPaths(rows)
+	if err != nil {
+		return 0, err
+	}
+	removeExportFiles(paths)
+	return len(paths), nil
+}
+
+// collectExportPaths reads the file_path column returned by a DELETE on
+// data_exports. Exports that never finished have an empty path.
+func collectExportPaths(rows pgx.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// removeExportFiles deletes archives whose rows are gone. Call it after the
+// deletion is committed.
+func removeExportFiles(paths []string) {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("⚠️  Failed to remove export %s: %v", path, err)
+		}
+	}
+}
+
+// StartExportCleaner runs PurgeExpiredExports every interval until ctx is done.
+func StartExportCleaner(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, interval, func() {
+		n, err := PurgeExpiredExports(ctx)
+		if err != nil {
+			log.Printf("⚠️  Export cleanup failed: %v", err)
+		} else if n > 0 {
+			log.Printf("🗑️  Removed %d expired data export(s)", n)
+		}
+	})
+}
+
+func buildDataExport(ctx context.Context, exportID, userID string) {
+	if _, err := database.Pool.Exec(ctx,
+		`UPDATE data_exports SET status = 'running' WHERE id = $1`, exportID); err != nil {
+		log.Printf("⚠️  Failed to start data export %s: %v", exportID, err)
+		return
+	}
+
+	path, size, err := writeExportArchive(ctx, exportID, userID)
+	if err != nil {
+		log.Printf("⚠️  Data export %s failed: %v", exportID, err)
+		_, _ = database.Pool.Exec(ctx,
+			`UPDATE data_exports SET status = 'failed', error = 'failed to build archive', completed_at = NOW()
+			 WHERE id = $1`, exportID)
+		return
+	}
+
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE data_exports SET status = 'ready', file_path = $2, size_bytes = $3, completed_at = NOW()
+		 WHERE id = $1`, exportID, path, size)
+	if err != nil {
+		log.Printf("⚠️  Failed to finish data export %s: %v", exportID, err)
+		_ = os.Remove(path)
+		return
+	}
+	// The account was deleted while the archive was being built
+	if tag.RowsAffected() == 0 {
+		_ = os.Remove(path)
+	}
+}
+
+// writeExportArchive writes the ZIP to a temporary file and moves it into
+// place once complete, so a download never sees a partial archive.
+func writeExportArchive(ctx context.Context, exportID, userID string) (string, int64, error) {
+	dir := exportDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	tmp, err := os.CreateTemp(dir, exportID+"-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	if err := writeExportFiles(ctx, zw, userID); err != nil {
+		return "", 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", 0, err
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	path := filepath.Join(dir, exportID+".zip")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func writeExportFiles(ctx context.Context, zw *zip.Writer, userID string) error {
+	var manifest strings.Builder
+	fmt.Fprintf(&manifest, "Data export for user %s\n", userID)
+	fmt.Fprintf(&manifest, "Generated at %s\n\n", time.Now().UTC().Format(time.RFC3339))
+	manifest.WriteString("Every table is included twice: as JSON and as CSV.\n\n")
+
+	var image string
+	for _, table := range exportTables {
+		columns, records, err := queryExportTable(ctx, table.query, userID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", table.name, err)
+		}
+		if table.name == "profile" && len(records) == 1 {
+			image, _ = records[0]["image"].(string)
+		}
+
+		if err := writeExportJSON(zw, table.name+".json", records); err != nil {
+			return err
+		}
+		if err := writeExportCSV(zw, table.name+".csv", columns, records); err != nil {
+			return err
+		}
+		fmt.Fprintf(&manifest, "%s.json, %s.csv\n    %s (%d rows)\n", table.name, table.name, table.description, len(records))
+	}
+
+	if image != "" {
+		name, err := writeExportAvatar(ctx, zw, image)
+		if err != nil {
+			log.Printf("⚠️  Could not include avatar in data export: %v", err)
+			manifest.WriteString("\nYour avatar could not be downloaded from " + image + "\n")
+		} else {
+			fmt.Fprintf(&manifest, "%s\n    Your profile picture\n", name)
+		}
+	}
+
+	w, err := zw.Create("README.txt")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, manifest.String())
+	return err
+}
+
+func queryExportTable(ctx context.Context, query, userID string) ([]string, []map[string]any, error) {
+	rows, err := database.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for _, fd := range rows.FieldDescriptions() {
+		columns = append(columns, fd.Name)
+	}
+
+	records := []map[string]any{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nil, err
+		}
+		record := make(map[string]any, len(columns))
+		for i, col := range columns {
+			record[col] = values[i]
+		}
+		records = append(records, record)
+	}
+	return columns, records, rows.Err()
+}
+
+func writeExportJSON(zw *zip.Writer, name string, records []map[string]any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func writeExportCSV(zw *zip.Writer, name string, columns []string, records []map[string]any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		line := make([]string, len(columns))
+		for i, col := range columns {
+			line[i] = csvValue(record[col])
+		}
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeExportAvatar downloads the profile picture into the archive and
+// returns its file name.
+func writeExportAvatar(ctx context.Context, zw *zip.Writer, imageURL string) (string, error) {
+	if !strings.HasPrefix(imageURL, "https://") {
+		return "", fmt.Errorf("avatar URL is not https")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("avatar request returned %s", resp.Status)
+	}
+
+	var ext string
+	switch strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0]) {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	case "image/gif":
+		ext = ".gif"
+	case "image/webp":
+		ext = ".webp"
+	default:
+		return "", fmt.Errorf("unexpected avatar content type %q", resp.Header.Get("Content-Type"))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAvatarBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxAvatarBytes {
+		return "", fmt.Errorf("avatar is larger than %d bytes", maxAvatarBytes)
+	}
+
+	name := "avatar" + ext
+	w, err := zw.Create(name)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(data)
+	return name, err
+}
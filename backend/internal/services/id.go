@@ -0,0 +1,22 @@
+package services
+
+// isUUID reports whether s is a UUID in the hyphenated form the API hands
+// out. Ids from URLs are checked with it before they reach a query, so a
+// malformed one reads as "not found" while lookups keep using the index.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package services
+
+import "testing"
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"0b6c4d2e-8f1a-4c3b-9d7e-5a2f1e0c9b8d", true},
+		{"0B6C4D2E-8F1A-4C3B-9D7E-5A2F1E0C9B8D", true},
+		{"", false},
+		{"42", false},
+		{"0b6c4d2e8f1a4c3b9d7e5a2f1e0c9b8d", false},
+		{"0b6c4d2e-8f1a-4c3b-9d7e-5a2f1e0c9b8g", false},
+		{"0b6c4d2e_8f1a-4c3b-9d7e-5a2f1e0c9b8d", false},
+		{"{0b6c4d2e-8f1a-4c3b-9d7e-5a2f1e0c9b8}", false},
+	}
+	for _, tt := range tests {
+		if got := isUUID(tt.in); got != tt.want {
+			t.Errorf("isUUID(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrIdentityInUse    = errors.New("identity is linked to another account")
+	ErrLastIdentity     = errors.New("cannot remove the only login identity")
+)
+
+// FindUserByIdentity finds the user that owns the (provider, subject) identity.
+func FindUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	row := database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM users
+		 WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)`,
+			userSelectFields), provider, subject)
+	return scanUser(row)
+}
+
+// GetUserIdentities lists the provider identities linked to a user.
+func GetUserIdentities(ctx context.Context, userID string) ([]models.UserIdentity, error) {
+	rows, err := database.Pool.Query(ctx,
+		`SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		 FROM user_identities WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.UserIdentity
+	for rows.Next() {
+		var i models.UserIdentity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	return identities, nil
+}
+
+// TouchIdentity records a successful login through an identity.
+func TouchIdentity(ctx context.Context, provider, subject, email string) error {
+	_, err := database.Pool.Exec(ctx,
+		`UPDATE user_identities SET last_login_at = NOW(), email = $3
+		 WHERE provider = $1 AND subject = $2`, provider, subject, email)
+	return err
+}
+
+// LinkIdentity attaches another provider identity to userID. Linking an
+// identity the user already has is a no-op.
+func LinkIdentity(ctx context.Context, userID, provider, subject, email string) (*models.UserIdentity, error) {
+	var i models.UserIdentity
+	err := database.Pool.QueryRow(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (provider, subject) DO UPDATE SET last_login_at = NOW(), email = EXCLUDED.email
+		 WHERE user_identities.user_id = EXCLUDED.user_id
+		 RETURNING id, user_id, provider, subject, email, created_at, last_login_at`,
+		userID, provider, subject, email).
+		Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		// The conflicting row belongs to someone else, so nothing was returned
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdentityInUse
+		}
+		return nil, err
+	}
+	return &i, nil
+}
+
+// UnlinkIdentity removes one of the user's identities, unless it is the
+// last one they can sign in with.
+func UnlinkIdentity(ctx context.Context, userID, identityID string) (*models.UserIdentity, error) {
+	if !isUUID(identityID) {
+		return nil, ErrIdentityNotFound
+	}
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize concurrent unlinks so two requests cannot remove the last two
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	var i models.UserIdentity
+	err = tx.QueryRow(ctx,
+		`SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		 FROM user_identities WHERE id = $1 AND user_id = $2`, identityID, userID).
+		Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+	if count <= 1 {
+		return nil, ErrLastIdentity
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE id = $1`, identityID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+// on the named constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+// ImpersonationTTL bounds an impersonation session. It gets no refresh
+// token, so it ends with its access token.
+const ImpersonationTTL = AccessTokenTTL
+
+var ErrNotImpersonating = errors.New("not an impersonation session")
+
+// StartImpersonation opens a session as target on behalf of actor and
+// returns its access token.
+func StartImpersonation(ctx context.Context, actor, target *models.User, ipAddress, userAgent string) (string, *models.Session, error) {
+	var s models.Session
+	err := database.Pool.QueryRow(ctx,
+		`INSERT INTO sessions (user_id, ip_address, user_agent, device, expires_at, impersonator_id)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, user_id, ip_address, user_agent, device, created_at, last_seen_at, expires_at, impersonator_id`,
+		target.ID, ipAddress, userAgent, deviceFromUserAgent(userAgent), time.Now().Add(ImpersonationTTL), actor.ID).
+		Scan(&s.ID, &s.UserID, &s.IPAddress, &s.UserAgent, &s.Device, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.ImpersonatorID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := GenerateImpersonationJWT(target, actor, s.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &s, nil
+}
+
+// EndImpersonation revokes the impersonation session and returns the
+// administrator who opened it.
+func EndImpersonation(ctx context.Context, userID, sessionID string) (string, error) {
+	var impersonatorID string
+	err := database.Pool.QueryRow(ctx,
+		`UPDATE sessions SET revoked_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND impersonator_id IS NOT NULL AND revoked_at IS NULL
+		 RETURNING impersonator_id`, sessionID, userID).Scan(&impersonatorID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotImpersonating
+		}
+		return "", err
+	}
+	return impersonatorID, nil
+}
+
+// GetImpersonator describes the administrator behind an impersonation session.
+func GetImpersonator(ctx context.Context, impersonatorID string) (*models.Impersonator, error) {
+	actor, err := FindUserByID(ctx, impersonatorID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Impersonator{ID: actor.ID, Email: actor.Email, Name: actor.Name}, nil
+}
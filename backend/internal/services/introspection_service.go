@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+var inactiveToken = &models.TokenIntrospection{Active: false}
+
+// IntrospectToken describes any token this backend issued (RFC 7662):
+// session access tokens, personal access tokens, and access tokens issued
+// to OAuth clients. Refresh tokens are only described to the client that
+// holds them. Revoked sessions, tokens and grants, and tokens of suspended
+// or deleted users, are inactive.
+func IntrospectToken(ctx context.Context, client *models.OAuthClient, token string) (*models.TokenIntrospection, error) {
+	if IsPersonalAccessToken(token) {
+		return introspectPersonalAccessToken(ctx, token)
+	}
+	if strings.Count(token, ".") == 2 {
+		return introspectJWT(ctx, token)
+	}
+	return introspectRefreshToken(ctx, client, token)
+}
+
+func introspectPersonalAccessToken(ctx context.Context, token string) (*models.TokenIntrospection, error) {
+	pat, err := ValidateAccessToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrAccessTokenNotFound) {
+			return inactiveToken, nil
+		}
+		return nil, err
+	}
+
+	result := &models.TokenIntrospection{
+		Active:    true,
+		Scope:     strings.Join(pat.Scopes, " "),
+		TokenType: "Bearer",
+		Iat:       pat.CreatedAt.Unix(),
+		Sub:       pat.UserID,
+	}
+	if pat.ExpiresAt != nil {
+		result.Exp = pat.ExpiresAt.Unix()
+	}
+	return withUsername(ctx, result)
+}
+
+func introspectJWT(ctx context.Context, token string) (*models.TokenIntrospection, error) {
+	// Issued to an OAuth client
+	if claims, err := ValidateOAuthAccessToken(ctx, token); err == nil {
+		return withUsername(ctx, &models.TokenIntrospection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			TokenType: "Bearer",
+			Exp:       unixTime(claims.ExpiresAt),
+			Iat:       unixTime(claims.IssuedAt),
+			Sub:       claims.Subject,
+			Aud:       claims.ClientID,
+			Iss:       claims.Issuer,
+		})
+	} else if !errors.Is(err, ErrInvalidOAuthToken) {
+		return nil, err
+	}
+
+	// Issued to a user session
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		return inactiveToken, nil
+	}
+	active, err := IsSessionActive(ctx, claims.ID, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return inactiveToken, nil
+	}
+	return withUsername(ctx, &models.TokenIntrospection{
+		Active:    true,
+		TokenType: "Bearer",
+		Exp:       unixTime(claims.ExpiresAt),
+		Iat:       unixTime(claims.IssuedAt),
+		Sub:       claims.UserID,
+		Iss:       claims.Issuer,
+	})
+}
+
+func introspectRefreshToken(ctx context.Context, client *models.OAuthClient, token string) (*models.TokenIntrospection, error) {
+	var (
+		userID, clientID     string
+		scopes               []string
+		createdAt, expiresAt time.Time
+	)
+	err := database.Pool.QueryRow(ctx,
+		`SELECT g.user_id, g.client_id, g.scopes, t.created_at, t.expires_at
+		 FROM oauth_refresh_tokens t JOIN oauth_grants g ON g.id = t.grant_id
+		 WHERE t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > NOW() AND g.revoked_at IS NULL`,
+		hashToken(token)).Scan(&userID, &clientID, &scopes, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return inactiveToken, nil
+		}
+		return nil, err
+	}
+	if clientID != client.ID {
+		return inactiveToken, nil
+	}
+
+	return withUsername(ctx, &models.TokenIntrospection{
+		Active:   true,
+		Scope:    strings.Join(scopes, " "),
+		ClientID: clientID,
+		Exp:      expiresAt.Unix(),
+		Iat:      createdAt.Unix(),
+		Sub:      userID,
+		Iss:      Issuer(),
+	})
+}
+
+// withUsername completes an active introspection result with the user's
+// username, or reports the token inactive if the user may no longer sign in.
+func withUsername(ctx context.Context, result *models.TokenIntrospection) (*models.TokenIntrospection, error) {
+	user, err := findAuthorizableUser(ctx, result.Sub)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGrant) {
+			return inactiveToken, nil
+		}
+		return nil, err
+	}
+	result.Username = user.Username
+	return result, nil
+}
+
+// RevokeClientToken revokes an access or refresh token issued to client
+// (RFC 7009), along with the grant it belongs to. Unknown tokens and
+// tokens of other clients are ignored.
+func RevokeClientToken(ctx context.Context, client *models.OAuthClient, token string) error {
+	var grantID string
+	if strings.Count(token, ".") == 2 {
+		claims := &OAuthAccessClaims{}
+		// An expired access token can still be revoked to end its grant
+		_, err := jwt.ParseWithClaims(token, claims, verificationKey, jwt.WithoutClaimsValidation())
+		if err != nil || claims.ClientID != client.ID || !isUUID(claims.ID) {
+			return nil
+		}
+		grantID = claims.ID
+	} else {
+		err := database.Pool.QueryRow(ctx,
+			`SELECT grant_id FROM oauth_refresh_tokens WHERE token_hash = $1`, hashToken(token)).Scan(&grantID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	_, err := database.Pool.Exec(ctx,
+		`UPDATE oauth_grants SET revoked_at = NOW()
+		 WHERE id = $1 AND client_id = $2 AND revoked_at IS NULL`, grantID, client.ID)
+	return err
+}
+
+func unixTime(t *jwt.NumericDate) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
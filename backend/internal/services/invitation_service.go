@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
+)
+
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidInvitation  = errors.New("invalid or expired invitation")
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+const invitationSelectFields = `id, email, max_uses, use_count, created_by, created_at, expires_at, revoked_at`
+
+// usableInvitation is the SQL condition for an invitation that can still
+// be redeemed.
+const usableInvitation = `revoked_at IS NULL AND expires_at > NOW() AND use_count < max_uses`
+
+func scanInvitation(row pgx.Row) (*models.Invitation, error) {
+	var i models.Invitation
+	err := row.Scan(&i.ID, &i.Email, &i.MaxUses, &i.UseCount, &i.CreatedBy, &i.CreatedAt, &i.ExpiresAt, &i.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// CreateInvitation returns a new invitation and its code, which is only
+// available at creation time. An invitation for an email address can be
+// used once; an open link up to req.MaxUses times (default 1).
+func CreateInvitation(ctx context.Context, createdBy string, req models.CreateInvitationRequest) (*models.Invitation, string, error) {
+	code, err := randomToken(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ttl := defaultInvitationTTL
+	if req.ExpiresInDays > 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	maxUses := req.MaxUses
+	if maxUses < 1 || req.Email != "" {
+		maxUses = 1
+	}
+
+	invitation, err := scanInvitation(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`INSERT INTO invitations (code_hash, email, max_uses, created_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING %s`, invitationSelectFields),
+		hashToken(code), strings.ToLower(req.Email), maxUses, createdBy, time.Now().Add(ttl)))
+	if err != nil {
+		return nil, "", err
+	}
+	return invitation, code, nil
+}
+
+// GetInvitations lists all invitations, newest first.
+func GetInvitations(ctx context.Context) ([]models.Invitation, error) {
+	rows, err := database.Pool.Query(ctx,
+		fmt.Sprintf(`SELECT %s FROM invitations ORDER BY created_at DESC`, invitationSelectFields))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []models.Invitation
+	for rows.Next() {
+		i, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *i)
+	}
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation stops an invitation from being used again.
+func RevokeInvitation(ctx context.Context, invitationID string) error {
+	if !isUUID(invitationID) {
+		return ErrInvitationNotFound
+	}
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE invitations SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, invitationID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// LookupInvitation finds a usable invitation by its code.
+func LookupInvitation(ctx context.Context, code string) (*models.Invitation, error) {
+	invitation, err := scanInvitation(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM invitations WHERE code_hash = $1 AND %s`, invitationSelectFields, usableInvitation),
+		hashToken(code)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// GetInvitationPreview describes a usable invitation to the invitee.
+func GetInvitationPreview(ctx context.Context, code string) (*models.InvitationPreview, error) {
+	invitation, err := LookupInvitation(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &models.InvitationPreview{Email: invitation.Email, ExpiresAt: invitation.ExpiresAt}
+	if invitation.CreatedBy != nil {
+		if inviter, err := FindUserByID(ctx, *invitation.CreatedBy); err == nil {
+			preview.InviterName = inviter.Name
+		}
+	}
+	return preview, nil
+}
+
+// findUsableInvitation loads the invitation carried in the OAuth state and
+// checks that profile may use it.
+func findUsableInvitation(ctx context.Context, invitationID string, profile *providers.Profile) (*models.Invitation, error) {
+	invitation, err := scanInvitation(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM invitations WHERE id = $1 AND %s`, invitationSelectFields, usableInvitation),
+		invitationID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+
+	if invitation.Email != "" && (!profile.EmailVerified || !strings.EqualFold(invitation.Email, profile.Email)) {
+		return nil, ErrInvalidInvitation
+	}
+	return invitation, nil
+}
+
+// redeemInvitation uses up one use of the invitation for the new user and
+// records the inviter on them.
+func redeemInvitation(ctx context.Context, tx pgx.Tx, invitationID, userID string) error {
+	var createdBy *string
+	err := tx.QueryRow(ctx,
+		fmt.Sprintf(`UPDATE invitations SET use_count = use_count + 1
+		 WHERE id = $1 AND %s RETURNING created_by`, usableInvitation), invitationID).Scan(&createdBy)
+	if err != nil {
+		// Used up or revoked since the sign-up started
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidInvitation
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO invitation_redemptions (invitation_id, user_id) VALUES ($1, $2)`, invitationID, userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE users SET invited_by = $2, invitation_id = $3 WHERE id = $1`, userID, createdBy, invitationID)
+	return err
+}
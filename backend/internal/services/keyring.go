@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth-app/backend/internal/oidc"
+)
+
+// signingKey is one key of the keyring.
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+	ModTime time.Time
+}
+
+// keyRing holds the active signing key and every key still accepted for
+// verification.
+type keyRing struct {
+	mu     sync.RWMutex
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var keyring = &keyRing{keys: map[string]*signingKey{}}
+
+// InitKeyring loads keys from dir. Each {kid}.pem file holds a private key
+// (PKCS#8, PKCS#1 or SEC 1); every key in dir verifies tokens, so a rotated
+// key stays valid until its file is removed. The active signing key is
+// activeKID, or the most recently modified key. When dir has no keys a new
+// one of type alg is generated and saved there.
+func InitKeyring(dir, alg, activeKID string) error {
+	keys := map[string]*signingKey{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read key dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+		key, err := loadKeyFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		keys[key.ID] = key
+	}
+
+	var active *signingKey
+	if activeKID != "" {
+		active = keys[activeKID]
+		if active == nil {
+			return fmt.Errorf("active signing key %q not found in %s", activeKID, dir)
+		}
+	} else {
+		for _, key := range keys {
+			if active == nil || key.ModTime.After(active.ModTime) {
+				active = key
+			}
+		}
+	}
+
+	if active == nil {
+		active, err = generateSigningKey(dir, alg)
+		if err != nil {
+			return err
+		}
+		keys[active.ID] = active
+	}
+
+	keyring.mu.Lock()
+	keyring.keys = keys
+	keyring.active = active
+	keyring.mu.Unlock()
+
+	log.Printf("✅ JWT signing key %s (%s), %d verification key(s)", active.ID, active.Method.Alg(), len(keys))
+	return nil
+}
+
+// PublicJWKS returns every verification key as a JWK set.
+func PublicJWKS() oidc.JWKSet {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	ids := make([]string, 0, len(keyring.keys))
+	for id := range keyring.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := oidc.JWKSet{Keys: []oidc.JWK{}}
+	for _, id := range ids {
+		key := keyring.keys[id]
+		jwk, err := oidc.NewJWK(key.ID, key.Method.Alg(), key.Public)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// signToken signs claims with the active key and sets the kid header.
+func signToken(claims jwt.Claims) (string, error) {
+	keyring.mu.RLock()
+	active := keyring.active
+	keyring.mu.RUnlock()
+
+	if active == nil {
+		return "", fmt.Errorf("no signing key configured")
+	}
+
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["kid"] = active.ID
+	return token.SignedString(active.Private)
+}
+
+// verificationKey is a jwt.Keyfunc resolving the token's kid.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	keyring.mu.RLock()
+	key, ok := keyring.keys[kid]
+	keyring.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+func loadKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+
+	key := &signingKey{
+		ID:      strings.TrimSuffix(filepath.Base(path), ".pem"),
+		ModTime: info.ModTime(),
+	}
+
+	// Public keys are refused; a retired key is kept as its private key file
+	switch block.Type {
+	case "PRIVATE KEY":
+		key.Private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key.Private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key.Private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: %q is not a private key", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	signer, ok := key.Private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key", path)
+	}
+	key.Public = signer.Public()
+
+	key.Method, err = signingMethodFor(key.Public)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func signingMethodFor(pub crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// generateSigningKey creates a key for alg and saves it to dir. If the key
+// cannot be written it is kept in memory only (tokens will not survive a
+// restart).
+func generateSigningKey(dir, alg string) (*signingKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch strings.ToUpper(alg) {
+	case "", "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EDDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q (use RS256, ES256 or EdDSA)", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := signingMethodFor(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key := &signingKey{
+		ID:      strings.ToLower(method.Alg()) + "-" + now.UTC().Format("20060102T150405"),
+		Method:  method,
+		Private: private,
+		Public:  private.Public(),
+		ModTime: now,
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	err = os.MkdirAll(dir, 0o700)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, key.ID+".pem"), data, 0o600)
+	}
+	if err != nil {
+		log.Printf("⚠️  Could not save generated signing key to %s: %v", dir, err)
+	} else {
+		log.Printf("🔑 Generated %s signing key %s", method.Alg(), key.ID)
+	}
+	return key, nil
+}
+
+// activeSigningAlg is the JWS algorithm of the key currently signing tokens.
+func activeSigningAlg() string {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	if keyring.active == nil {
+		return ""
+	}
+	return keyring.active.Method.Alg()
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey saves a new P-256 key as dir/{kid}.pem, last modified at modTime.
+func writeKey(t *testing.T, dir, kid string, modTime time.Time) *ecdsa.PrivateKey {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func signedKID(t *testing.T) (string, string) {
+	t.Helper()
+	signed, err := signToken(jwt.RegisteredClaims{Subject: "user"})
+	if err != nil {
+		t.Fatalf("signToken: %v", err)
+	}
+	token, err := jwt.Parse(signed, verificationKey)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	return signed, token.Header["kid"].(string)
+}
+
+func TestInitKeyringGeneratesAndReloadsKey(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	if err := InitKeyring(dir, "ES256", ""); err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	_, kid := signedKID(t)
+	if !strings.HasPrefix(kid, "es256-") {
+		t.Errorf("generated kid = %q", kid)
+	}
+
+	// The saved key is picked up again instead of generating another
+	if err := InitKeyring(dir, "ES256", ""); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, again := signedKID(t); again != kid {
+		t.Errorf("kid after reload = %q, want %q", again, kid)
+	}
+}
+
+func TestInitKeyringRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeKey(t, dir, "old", now.Add(-time.Hour))
+
+	if err := InitKeyring(dir, "", ""); err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	oldToken, kid := signedKID(t)
+	if kid != "old" {
+		t.Fatalf("kid = %q, want old", kid)
+	}
+
+	// A newer key takes over signing; the old one still verifies
+	writeKey(t, dir, "new", now)
+	if err := InitKeyring(dir, "", ""); err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	if _, kid := signedKID(t); kid != "new" {
+		t.Errorf("kid = %q, want new", kid)
+	}
+	if _, err := jwt.Parse(oldToken, verificationKey); err != nil {
+		t.Errorf("token from the rotated key: %v", err)
+	}
+	if got := len(PublicJWKS().Keys); got != 2 {
+		t.Errorf("JWKS has %d keys, want 2", got)
+	}
+
+	// JWT_ACTIVE_KID overrides the newest key
+	if err := InitKeyring(dir, "", "old"); err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	if _, kid := signedKID(t); kid != "old" {
+		t.Errorf("kid = %q, want old", kid)
+	}
+	if err := InitKeyring(dir, "", "missing"); err == nil {
+		t.Error("unknown active kid accepted")
+	}
+
+	// Once its file is gone the old key no longer verifies
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitKeyring(dir, "", ""); err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	if _, err := jwt.Parse(oldToken, verificationKey); err == nil {
+		t.Error("token from a removed key still verifies")
+	}
+}
+
+func TestInitKeyringRejectsPublicKeys(t *testing.T) {
+	dir := t.TempDir()
+	private := writeKey(t, dir, "signing", time.Now())
+
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "retired.pub.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitKeyring(dir, "", ""); err == nil || !strings.Contains(err.Error(), "not a private key") {
+		t.Errorf("InitKeyring = %v, want a public key to be refused", err)
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+)
+
+const loginCodeTTL = time.Minute
+
+var ErrInvalidLoginCode = errors.New("invalid login code")
+
+// IsAllowedAppRedirect reports whether a native app may receive login codes
+// at uri: an exact match in NATIVE_REDIRECT_URIS (e.g. "myapp://auth"), or
+// a loopback http URI on any port as described in RFC 8252 §7.3.
+func IsAllowedAppRedirect(uri string) bool {
+	allowed := strings.Split(os.Getenv("NATIVE_REDIRECT_URIS"), ",")
+	for i := range allowed {
+		allowed[i] = strings.TrimSpace(allowed[i])
+	}
+	if uri != "" && slices.Contains(allowed, uri) {
+		return true
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// CreateLoginCode stores a short-lived, single-use code that an app can
+// redeem for tokens by proving possession of the PKCE verifier.
+func CreateLoginCode(ctx context.Context, userID, codeChallenge, redirectURI string) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = database.Pool.Exec(ctx,
+		`INSERT INTO login_codes (code_hash, user_id, code_challenge, redirect_uri, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		hashToken(code), userID, codeChallenge, redirectURI, time.Now().Add(loginCodeTTL))
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemLoginCode consumes a login code and returns the user it was issued for.
+func RedeemLoginCode(ctx context.Context, code, codeVerifier, redirectURI string) (string, error) {
+	var userID, challenge, storedRedirect string
+	err := database.Pool.QueryRow(ctx,
+		`UPDATE login_codes SET used_at = NOW()
+		 WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		 RETURNING user_id, code_challenge, redirect_uri`, hashToken(code)).
+		Scan(&userID, &challenge, &storedRedirect)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrInvalidLoginCode
+		}
+		return "", err
+	}
+
+	if storedRedirect != redirectURI || !VerifyPKCE(challenge, codeVerifier) {
+		return "", ErrInvalidLoginCode
+	}
+	return userID, nil
+}
+
+// VerifyPKCE checks an S256 code challenge against its verifier.
+func VerifyPKCE(challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
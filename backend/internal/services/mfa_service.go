@@ -0,0 +1,436 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+const (
+	// MFATokenCookie identifies the mfa_pending session between the OAuth
+	// callback and the second-factor check.
+	MFATokenCookie = "mfa_token"
+	MFATokenTTL    = 5 * time.Minute
+
+	// Second-factor methods, as reported by CompleteMFA / VerifySecondFactor
+	MFAMethodTOTP         = "totp"
+	MFAMethodPasskey      = "passkey"
+	MFAMethodRecoveryCode = "recovery_code"
+
+	mfaTokenAudience  = "mfa"
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+
+	// How long code checks stay locked after maxMFAAttempts failures
+	MFALockout = 15 * time.Minute
+)
+
+var (
+	ErrInvalidMFACode          = errors.New("invalid verification code")
+	ErrTooManyMFAAttempts      = errors.New("too many verification attempts")
+	ErrMFAAlreadyEnabled       = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled           = errors.New("two-factor authentication is not enabled")
+	ErrMFAEnrollmentNotStarted = errors.New("no pending two-factor enrollment")
+)
+
+// MFAClaims is the short-lived token held by a browser whose login is
+// waiting for its second factor. The jti is the mfa_pending session; the
+// native app fields carry the login through to the one-time code.
+type MFAClaims struct {
+	AppRedirectURI   string `json:"ar,omitempty"`
+	AppCodeChallenge string `json:"ac,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func GenerateMFAToken(userID, sessionID, appRedirectURI, appCodeChallenge string) (string, error) {
+	claims := MFAClaims{
+		AppRedirectURI:   appRedirectURI,
+		AppCodeChallenge: appCodeChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{mfaTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFATokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    tokenIssuer,
+		},
+	}
+	return signToken(claims)
+}
+
+func ValidateMFAToken(tokenString string) (*MFAClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &MFAClaims{}, verificationKey,
+		jwt.WithAudience(mfaTokenAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*MFAClaims); ok && token.Valid && claims.ID != "" && claims.Subject != "" {
+		return claims, nil
+	}
+	return nil, fmt.Errorf("invalid token")
+}
+
+// IsMFAEnabled reports whether logins need a second factor: the user has
+// confirmed TOTP or registered at least one passkey.
+func IsMFAEnabled(ctx context.Context, userID string) (bool, error) {
+	methods, err := GetMFAMethods(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range methods {
+		if m != MFAMethodRecoveryCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetMFAMethods lists the second factors the user can currently present.
+func GetMFAMethods(ctx context.Context, userID string) ([]string, error) {
+	status, err := GetMFAStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []string{}
+	if status.TOTPEnabled {
+		methods = append(methods, MFAMethodTOTP)
+	}
+	if status.Passkeys > 0 {
+		methods = append(methods, MFAMethodPasskey)
+	}
+	if status.RecoveryCodesRemaining > 0 {
+		methods = append(methods, MFAMethodRecoveryCode)
+	}
+	return methods, nil
+}
+
+func GetMFAStatus(ctx context.Context, userID string) (*models.MFAStatus, error) {
+	var status models.MFAStatus
+	err := database.Pool.QueryRow(ctx,
+		`SELECT (SELECT enabled_at FROM user_totp WHERE user_id = $1),
+		        (SELECT COUNT(*) FROM webauthn_credentials WHERE user_id = $1),
+		        (SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL)`, userID).
+		Scan(&status.TOTPEnabledAt, &status.Passkeys, &status.RecoveryCodesRemaining)
+	if err != nil {
+		return nil, err
+	}
+	status.TOTPEnabled = status.TOTPEnabledAt != nil
+	return &status, nil
+}
+
+// BeginTOTPEnrollment generates a new secret for the user. It replaces any
+// unconfirmed enrollment but never an active one.
+func BeginTOTPEnrollment(ctx context.Context, userID, account string) (*models.TOTPEnrollment, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := database.Pool.Exec(ctx,
+		`INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		 WHERE user_totp.enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "OAuth App"
+	}
+	return &models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: TOTPProvisioningURI(secret, account, issuer),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP once the user proves their
+// authenticator works, and returns the initial recovery codes.
+func ConfirmTOTPEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var secret string
+	err = tx.QueryRow(ctx,
+		`SELECT secret FROM user_totp WHERE user_id = $1 AND enabled_at IS NULL FOR UPDATE`, userID).
+		Scan(&secret)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMFAEnrollmentNotStarted
+		}
+		return nil, err
+	}
+
+	step, ok := ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE user_totp SET enabled_at = NOW(), last_used_step = $2 WHERE user_id = $1`,
+		userID, step); err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP removes the user's TOTP secret, and the recovery codes unless
+// passkeys still need them. Callers must check a second factor first.
+func DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFANotEnabled
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = $1
+		 AND NOT EXISTS (SELECT 1 FROM webauthn_credentials WHERE user_id = $1)`, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RegenerateRecoveryCodes invalidates all existing recovery codes and
+// returns a new set.
+func RegenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused
+// recovery code (which is consumed) and reports which one matched. After
+// maxMFAAttempts failures in a row, code checks are locked for MFALockout.
+func VerifySecondFactor(ctx context.Context, userID, code string) (string, error) {
+	enabled, err := IsMFAEnabled(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !enabled {
+		return "", ErrMFANotEnabled
+	}
+
+	if err := countCodeAttempt(ctx, userID); err != nil {
+		return "", err
+	}
+
+	method := MFAMethodRecoveryCode
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) == totpDigits {
+		method = MFAMethodTOTP
+		err = verifyTOTPCode(ctx, userID, code)
+	} else {
+		err = useRecoveryCode(ctx, userID, code)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = database.Pool.Exec(ctx, `UPDATE users SET mfa_failed_attempts = 0 WHERE id = $1`, userID)
+	return method, err
+}
+
+// CompleteMFA upgrades an mfa_pending session once the user presents a
+// valid TOTP or recovery code.
+func CompleteMFA(ctx context.Context, userID, sessionID, code string) (string, error) {
+	if err := countMFAAttempt(ctx, userID, sessionID); err != nil {
+		return "", err
+	}
+
+	method, err := VerifySecondFactor(ctx, userID, code)
+	if err != nil {
+		return "", err
+	}
+	return method, upgradeMFASession(ctx, sessionID)
+}
+
+// CompleteMFAWithPasskey upgrades an mfa_pending session with a passkey
+// assertion started by BeginPasskeyLogin(userID).
+func CompleteMFAWithPasskey(ctx context.Context, userID, sessionID, challengeID string, response []byte) error {
+	if err := countMFAAttempt(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	if err := VerifyPasskey(ctx, userID, challengeID, response); err != nil {
+		return err
+	}
+	return upgradeMFASession(ctx, sessionID)
+}
+
+// countMFAAttempt checks the session is still waiting for its second factor
+// and revokes it after too many failures.
+func countMFAAttempt(ctx context.Context, userID, sessionID string) error {
+	var attempts int
+	err := database.Pool.QueryRow(ctx,
+		`UPDATE sessions SET mfa_attempts = mfa_attempts + 1
+		 WHERE id = $1 AND user_id = $2 AND mfa_pending AND revoked_at IS NULL AND expires_at > NOW()
+		 RETURNING mfa_attempts`, sessionID, userID).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if attempts > maxMFAAttempts {
+		_ = RevokeSession(ctx, userID, sessionID)
+		return ErrTooManyMFAAttempts
+	}
+	return nil
+}
+
+// countCodeAttempt counts a code check against the user and locks further
+// checks once too many have failed in a row; a correct code resets the count.
+func countCodeAttempt(ctx context.Context, userID string) error {
+	var attempts int
+	err := database.Pool.QueryRow(ctx,
+		`UPDATE users SET mfa_failed_attempts = mfa_failed_attempts + 1
+		 WHERE id = $1 AND (mfa_locked_until IS NULL OR mfa_locked_until <= NOW())
+		 RETURNING mfa_failed_attempts`, userID).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTooManyMFAAttempts
+		}
+		return err
+	}
+	if attempts > maxMFAAttempts {
+		if _, err := database.Pool.Exec(ctx,
+			`UPDATE users SET mfa_failed_attempts = 0, mfa_locked_until = $2 WHERE id = $1`,
+			userID, time.Now().Add(MFALockout)); err != nil {
+			return err
+		}
+		return ErrTooManyMFAAttempts
+	}
+	return nil
+}
+
+func upgradeMFASession(ctx context.Context, sessionID string) error {
+	_, err := database.Pool.Exec(ctx,
+		`UPDATE sessions SET mfa_pending = false, authenticated_at = NOW(), second_factor_at = NOW(), last_seen_at = NOW()
+		 WHERE id = $1`, sessionID)
+	return err
+}
+
+// verifyTOTPCode checks a code and records its time step so the same code
+// cannot be replayed within its validity window.
+func verifyTOTPCode(ctx context.Context, userID, code string) error {
+	var (
+		secret       string
+		lastUsedStep int64
+	)
+	err := database.Pool.QueryRow(ctx,
+		`SELECT secret, last_used_step FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL`, userID).
+		Scan(&secret, &lastUsedStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+
+	step, ok := ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= lastUsedStep {
+		return ErrInvalidMFACode
+	}
+
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func useRecoveryCode(ctx context.Context, userID, code string) error {
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE recovery_codes SET used_at = NOW()
+		 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, hashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code like "k3m9q-x7p2a".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
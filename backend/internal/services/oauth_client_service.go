@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	ErrInvalidRedirectURI  = errors.New("invalid redirect uri")
+	ErrInvalidClient       = errors.New("invalid client credentials")
+)
+
+const oauthClientSelectFields = `id, name, secret_hash IS NULL, redirect_uris, created_by, created_at, revoked_at`
+
+func scanOAuthClient(row pgx.Row) (*models.OAuthClient, error) {
+	var cl models.OAuthClient
+	err := row.Scan(&cl.ID, &cl.Name, &cl.Public, &cl.RedirectURIs, &cl.CreatedBy, &cl.CreatedAt, &cl.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// CreateOAuthClient registers an application. Confidential clients get a
+// secret, which is only available at creation time.
+func CreateOAuthClient(ctx context.Context, createdBy string, req models.CreateOAuthClientRequest) (*models.OAuthClient, string, error) {
+	for _, uri := range req.RedirectURIs {
+		if !isValidRedirectURI(uri) {
+			return nil, "", fmt.Errorf("%w %q", ErrInvalidRedirectURI, uri)
+		}
+	}
+
+	var secret string
+	var secretHash *string
+	if !req.Public {
+		var err error
+		secret, err = randomToken(32)
+		if err != nil {
+			return nil, "", err
+		}
+		h := hashToken(secret)
+		secretHash = &h
+	}
+
+	client, err := scanOAuthClient(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`INSERT INTO oauth_clients (name, secret_hash, redirect_uris, created_by)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING %s`, oauthClientSelectFields),
+		req.Name, secretHash, req.RedirectURIs, createdBy))
+	if err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// GetOAuthClients lists registered clients, newest first.
+func GetOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	rows, err := database.Pool.Query(ctx,
+		fmt.Sprintf(`SELECT %s FROM oauth_clients ORDER BY created_at DESC`, oauthClientSelectFields))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []models.OAuthClient
+	for rows.Next() {
+		cl, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *cl)
+	}
+	return clients, rows.Err()
+}
+
+// RevokeOAuthClient disables a client and everything it was granted.
+func RevokeOAuthClient(ctx context.Context, clientID string) error {
+	if !isUUID(clientID) {
+		return ErrOAuthClientNotFound
+	}
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE oauth_clients SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, clientID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOAuthClientNotFound
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE oauth_grants SET revoked_at = NOW() WHERE client_id = $1 AND revoked_at IS NULL`, clientID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// FindOAuthClient returns an active client by its client_id.
+func FindOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	// A malformed client_id is simply unknown
+	if !isUUID(clientID) {
+		return nil, ErrOAuthClientNotFound
+	}
+	client, err := scanOAuthClient(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM oauth_clients WHERE id = $1 AND revoked_at IS NULL`, oauthClientSelectFields),
+		clientID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// AuthenticateClient checks client credentials at the token endpoint.
+// Public clients authenticate with their client_id alone.
+func AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	if !isUUID(clientID) {
+		return nil, ErrInvalidClient
+	}
+	var secretHash *string
+	err := database.Pool.QueryRow(ctx,
+		`SELECT secret_hash FROM oauth_clients WHERE id = $1 AND revoked_at IS NULL`, clientID).Scan(&secretHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	if secretHash != nil && subtle.ConstantTimeCompare([]byte(*secretHash), []byte(hashToken(secret))) != 1 {
+		return nil, ErrInvalidClient
+	}
+	if secretHash == nil && secret != "" {
+		// A public client has nothing to authenticate with
+		return nil, ErrInvalidClient
+	}
+	return FindOAuthClient(ctx, clientID)
+}
+
+// AllowsRedirect reports whether uri is registered for the client. Redirect
+// URIs must match exactly.
+func AllowsRedirect(client *models.OAuthClient, uri string) bool {
+	return uri != "" && slices.Contains(client.RedirectURIs, uri)
+}
+
+// isValidRedirectURI accepts absolute URIs without a fragment: https, http
+// on loopback hosts, or a custom scheme for native apps.
+func isValidRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		return u.Hostname() == "localhost" || IsAllowedAppRedirect(uri)
+	case "javascript", "data", "vbscript", "file":
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/oauth-app/backend/internal/models"
+)
+
+func TestParseOAuthScopes(t *testing.T) {
+	scopes, err := parseOAuthScopes("openid  email openid profile")
+	if err != nil {
+		t.Fatalf("parseOAuthScopes: %v", err)
+	}
+	if want := []string{"openid", "email", "profile"}; !slices.Equal(scopes, want) {
+		t.Errorf("scopes = %v, want %v", scopes, want)
+	}
+
+	for _, scope := range []string{"", "email profile", "openid admin"} {
+		if _, err := parseOAuthScopes(scope); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("parseOAuthScopes(%q) error = %v, want ErrInvalidScope", scope, err)
+		}
+	}
+}
+
+func TestUserInfoClaims(t *testing.T) {
+	user := &models.User{ID: "u1", Name: "Ann", Email: "ann@example.com", Username: "ann"}
+
+	claims := UserInfoClaims(user, []string{models.OAuthScopeOpenID})
+	if len(claims) != 1 || claims["sub"] != "u1" {
+		t.Errorf("openid only: claims = %v, want just sub", claims)
+	}
+
+	claims = UserInfoClaims(user, []string{models.OAuthScopeOpenID, models.OAuthScopeEmail})
+	if claims["email"] != "ann@example.com" || claims["name"] != nil {
+		t.Errorf("email scope: claims = %v", claims)
+	}
+
+	claims = UserInfoClaims(user, []string{models.OAuthScopeOpenID, models.OAuthScopeProfile})
+	if claims["preferred_username"] != "ann" || claims["email"] != nil {
+		t.Errorf("profile scope: claims = %v", claims)
+	}
+}
+
+func TestIsValidRedirectURI(t *testing.T) {
+	cases := map[string]bool{
+		"https://app.example.com/callback": true,
+		"http://localhost:3000/callback":   true,
+		"http://127.0.0.1:8123/cb":         true,
+		"com.example.app:/oauth":           true,
+		"http://app.example.com/callback":  false,
+		"https://app.example.com/cb#frag":  false,
+		"javascript:alert(1)":              false,
+		"/relative/callback":               false,
+	}
+	for uri, want := range cases {
+		if got := isValidRedirectURI(uri); got != want {
+			t.Errorf("isValidRedirectURI(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
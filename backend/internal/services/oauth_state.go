@@ -0,0 +1,163 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	OAuthStateCookie = "oauth_state"
+	OAuthStateTTL    = 10 * time.Minute
+)
+
+var ErrStateMismatch = errors.New("oauth state mismatch")
+
+// OAuthState is carried in a signed cookie for the duration of one login
+// round-trip. State is echoed back by the provider and Nonce comes back
+// inside the ID token; CodeVerifier never leaves the browser cookie / backend.
+type OAuthState struct {
+	Provider     string `json:"p"`
+	State        string `json:"s"`
+	Nonce        string `json:"n"`
+	CodeVerifier string `json:"v"`
+	ExpiresAt    int64  `json:"exp"`
+
+	// Native app login (RFC 8252): where to deliver the one-time login
+	// code and the app's own PKCE challenge for redeeming it.
+	AppRedirectURI   string `json:"ar,omitempty"`
+	AppCodeChallenge string `json:"ac,omitempty"`
+
+	// Step-up re-authentication of an existing session instead of a login.
+	// ReturnTo is the frontend path to go back to afterwards.
+	ReauthSessionID string `json:"rs,omitempty"`
+	ReauthUserID    string `json:"ru,omitempty"`
+	ReturnTo        string `json:"rt,omitempty"`
+
+	// Linking another identity to a signed-in user instead of a login.
+	LinkUserID string `json:"lu,omitempty"`
+
+	// Invitation checked when the login started, redeemed if it signs up.
+	InvitationID string `json:"iv,omitempty"`
+}
+
+// consumedStates remembers states that already completed a callback so a
+// captured cookie + callback URL pair cannot be replayed before it expires.
+var consumedStates = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: map[string]time.Time{}}
+
+// NewOAuthState creates a fresh state, nonce and PKCE verifier for provider.
+// Callers may fill in the optional fields before signing it with SignOAuthState.
+func NewOAuthState(provider string) (*OAuthState, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthState{
+		Provider:     provider,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ExpiresAt:    time.Now().Add(OAuthStateTTL).Unix(),
+	}, nil
+}
+
+// VerifyOAuthState checks the cookie signature, expiry and that the state
+// returned by provider matches. A state can only be verified once.
+func VerifyOAuthState(provider, cookie, state string) (*OAuthState, error) {
+	payload, sig, ok := strings.Cut(cookie, ".")
+	if !ok || state == "" {
+		return nil, ErrStateMismatch
+	}
+
+	expected := stateMAC([]byte(payload))
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, expected) {
+		return nil, ErrStateMismatch
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrStateMismatch
+	}
+
+	var st OAuthState
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return nil, ErrStateMismatch
+	}
+
+	if st.Provider != provider || subtle.ConstantTimeCompare([]byte(st.State), []byte(state)) != 1 {
+		return nil, ErrStateMismatch
+	}
+
+	expiresAt := time.Unix(st.ExpiresAt, 0)
+	if time.Now().After(expiresAt) {
+		return nil, ErrStateMismatch
+	}
+
+	if !consumeState(st.State, expiresAt) {
+		return nil, ErrStateMismatch
+	}
+
+	return &st, nil
+}
+
+// SignOAuthState returns the cookie value that binds st to the browser.
+func SignOAuthState(st *OAuthState) (string, error) {
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	sig := base64.RawURLEncoding.EncodeToString(stateMAC([]byte(payload)))
+	return payload + "." + sig, nil
+}
+
+func stateMAC(payload []byte) []byte {
+	mac := hmac.New(sha256.New, HMACSecret)
+	mac.Write([]byte("oauth-state:"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func consumeState(state string, expiresAt time.Time) bool {
+	consumedStates.Lock()
+	defer consumedStates.Unlock()
+
+	now := time.Now()
+	for s, exp := range consumedStates.m {
+		if now.After(exp) {
+			delete(consumedStates.m, s)
+		}
+	}
+
+	if _, used := consumedStates.m[state]; used {
+		return false
+	}
+	consumedStates.m[state] = expiresAt
+	return true
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	HMACSecret = []byte("test-secret")
+}
+
+func newSignedState(t *testing.T) (*OAuthState, string) {
+	t.Helper()
+	st, err := NewOAuthState("google")
+	if err != nil {
+		t.Fatalf("NewOAuthState: %v", err)
+	}
+	cookie, err := SignOAuthState(st)
+	if err != nil {
+		t.Fatalf("SignOAuthState: %v", err)
+	}
+	return st, cookie
+}
+
+func TestVerifyOAuthState(t *testing.T) {
+	st, cookie := newSignedState(t)
+	if st.CodeVerifier == "" {
+		t.Fatal("expected a PKCE code verifier")
+	}
+
+	got, err := VerifyOAuthState("google", cookie, st.State)
+	if err != nil {
+		t.Fatalf("VerifyOAuthState: %v", err)
+	}
+	if got.CodeVerifier != st.CodeVerifier {
+		t.Errorf("verifier = %q, want %q", got.CodeVerifier, st.CodeVerifier)
+	}
+}
+
+func TestVerifyOAuthStateReplayed(t *testing.T) {
+	st, cookie := newSignedState(t)
+
+	if _, err := VerifyOAuthState("google", cookie, st.State); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := VerifyOAuthState("google", cookie, st.State); err != ErrStateMismatch {
+		t.Errorf("replay: err = %v, want ErrStateMismatch", err)
+	}
+}
+
+func TestVerifyOAuthStateExpired(t *testing.T) {
+	st := &OAuthState{
+		Provider:     "google",
+		State:        "expired-state",
+		CodeVerifier: "verifier",
+		ExpiresAt:    time.Now().Add(-time.Minute).Unix(),
+	}
+	cookie, err := SignOAuthState(st)
+	if err != nil {
+		t.Fatalf("SignOAuthState: %v", err)
+	}
+
+	if _, err := VerifyOAuthState("google", cookie, st.State); err != ErrStateMismatch {
+		t.Errorf("err = %v, want ErrStateMismatch", err)
+	}
+}
+
+func TestVerifyOAuthStateForged(t *testing.T) {
+	st, cookie := newSignedState(t)
+
+	payload, sig, _ := strings.Cut(cookie, ".")
+
+	forged := &OAuthState{
+		Provider:     "google",
+		State:        "attacker-state",
+		CodeVerifier: "attacker-verifier",
+		ExpiresAt:    time.Now().Add(time.Minute).Unix(),
+	}
+	HMACSecret = []byte("other-secret")
+	otherKey, _ := SignOAuthState(forged)
+	HMACSecret = []byte("test-secret")
+
+	tests := []struct {
+		name     string
+		provider string
+		cookie   string
+		state    string
+	}{
+		{"wrong state", "google", cookie, "not-the-state"},
+		{"empty state", "google", cookie, ""},
+		{"wrong provider", "github", cookie, st.State},
+		{"missing cookie", "google", "", st.State},
+		{"tampered signature", "google", payload + "." + sig[:len(sig)-2] + "AA", st.State},
+		{"unsigned", "google", payload, st.State},
+		{"signed with other key", "google", otherKey, forged.State},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyOAuthState(tt.provider, tt.cookie, tt.state); err != ErrStateMismatch {
+				t.Errorf("err = %v, want ErrStateMismatch", err)
+			}
+		})
+	}
+
+	// The genuine pair must still be usable after the failed attempts
+	if _, err := VerifyOAuthState("google", cookie, st.State); err != nil {
+		t.Errorf("genuine state rejected: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
+)
+
+var (
+	ErrUnknownProfileField = errors.New("unknown profile field")
+	ErrInvalidSyncPolicy   = errors.New("invalid sync policy")
+)
+
+// defaultSyncPolicies applies to fields the user has not configured. The
+// email follows the provider so address changes there are not lost.
+var defaultSyncPolicies = map[string]string{
+	models.ProfileFieldName:  models.SyncUntilEdited,
+	models.ProfileFieldEmail: models.SyncAlways,
+	models.ProfileFieldImage: models.SyncUntilEdited,
+}
+
+// GetProfileSync returns the sync policy of every syncable field.
+func GetProfileSync(ctx context.Context, userID string) (map[string]models.ProfileFieldSync, error) {
+	settings := make(map[string]models.ProfileFieldSync, len(models.SyncableProfileFields))
+	for _, field := range models.SyncableProfileFields {
+		settings[field] = models.ProfileFieldSync{Policy: defaultSyncPolicies[field]}
+	}
+
+	rows, err := database.Pool.Query(ctx,
+		`SELECT field, policy, edited_at FROM profile_sync_settings WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var field string
+		var s models.ProfileFieldSync
+		if err := rows.Scan(&field, &s.Policy, &s.EditedAt); err != nil {
+			return nil, err
+		}
+		if _, ok := settings[field]; ok {
+			settings[field] = s
+		}
+	}
+	return settings, rows.Err()
+}
+
+// UpdateProfileSync sets the policy of the given fields. Changing a policy
+// forgets earlier edits, so until_edited starts syncing again.
+func UpdateProfileSync(ctx context.Context, userID string, policies map[string]string) error {
+	for field, policy := range policies {
+		if !slices.Contains(models.SyncableProfileFields, field) {
+			return fmt.Errorf("%w: %s", ErrUnknownProfileField, field)
+		}
+		if !slices.Contains(models.SyncPolicies, policy) {
+			return fmt.Errorf("%w: %s", ErrInvalidSyncPolicy, policy)
+		}
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for field, policy := range policies {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO profile_sync_settings (user_id, field, policy) VALUES ($1, $2, $3)
+			 ON CONFLICT (user_id, field) DO UPDATE SET policy = EXCLUDED.policy, edited_at = NULL`,
+			userID, field, policy); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// markProfileFieldEdited records that the user changed field themselves.
+func markProfileFieldEdited(ctx context.Context, userID, field string) error {
+	_, err := database.Pool.Exec(ctx,
+		`INSERT INTO profile_sync_settings (user_id, field, policy, edited_at) VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (user_id, field) DO UPDATE SET edited_at = NOW()`,
+		userID, field, defaultSyncPolicies[field])
+	return err
+}
+
+// SyncProfile copies changed provider data into the user's profile
+// according to their sync policies and returns what was changed. Only the
+// primary (first linked) identity is synced from, so linked work and
+// personal accounts do not overwrite each other on every login.
+func SyncProfile(ctx context.Context, user *models.User, profile *providers.Profile) ([]models.ProfileFieldChange, error) {
+	var provider, subject string
+	err := database.Pool.QueryRow(ctx,
+		`SELECT provider, subject FROM user_identities
+		 WHERE user_id = $1 ORDER BY created_at LIMIT 1`, user.ID).Scan(&provider, &subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if provider != profile.Provider || subject != profile.Subject {
+		return nil, nil
+	}
+
+	settings, err := GetProfileSync(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []models.ProfileFieldChange{
+		{Field: models.ProfileFieldName, OldValue: user.Name, NewValue: profile.Name},
+		{Field: models.ProfileFieldImage, OldValue: user.Image, NewValue: profile.Picture},
+	}
+	// An unverified address could belong to someone else
+	if profile.EmailVerified {
+		candidates = append(candidates,
+			models.ProfileFieldChange{Field: models.ProfileFieldEmail, OldValue: user.Email, NewValue: profile.Email})
+	}
+
+	var changes []models.ProfileFieldChange
+	for _, change := range candidates {
+		s := settings[change.Field]
+		if change.NewValue == "" || change.NewValue == change.OldValue {
+			continue
+		}
+		if s.Policy == models.SyncNever || (s.Policy == models.SyncUntilEdited && s.EditedAt != nil) {
+			continue
+		}
+
+		// change.Field is one of the fixed column names above
+		_, err := database.Pool.Exec(ctx,
+			fmt.Sprintf(`UPDATE users SET %s = $1, updated_at = NOW() WHERE id = $2`, change.Field),
+			change.NewValue, user.ID)
+		if err != nil {
+			if isUniqueViolation(err, "users_email_key") {
+				log.Printf("Skipping email sync for user %s: address belongs to another account", user.ID)
+				continue
+			}
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/oauth-app/backend/internal/providers"
+)
+
+// GetReauthMethods lists how the user can re-authenticate: any linked
+// login provider that supports it, plus TOTP and passkeys when set up.
+func GetReauthMethods(ctx context.Context, userID string) ([]string, error) {
+	identities, err := GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []string{}
+	for _, identity := range identities {
+		p, ok := providers.Get(identity.Provider)
+		if ok && providers.SupportsReauth(p) && !slices.Contains(methods, identity.Provider) {
+			methods = append(methods, identity.Provider)
+		}
+	}
+
+	mfaMethods, err := GetMFAMethods(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mfaMethods {
+		if m != MFAMethodRecoveryCode {
+			methods = append(methods, m)
+		}
+	}
+	return methods, nil
+}
+
+// SafeReturnPath keeps a post-login redirect on the frontend: only local
+// absolute paths are accepted.
+func SafeReturnPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/dashboard"
+	}
+	return path
+}
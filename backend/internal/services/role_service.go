@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+var (
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrCannotChangeOwnRole = errors.New("you cannot change your own role")
+	ErrNotAllowedOnTarget  = errors.New("not allowed for a user with this role")
+)
+
+// HasPermission reports whether role grants permission.
+func HasPermission(role, permission string) bool {
+	return slices.Contains(models.RolePermissions[role], permission)
+}
+
+// CanManage reports whether a user with actorRole may act on (suspend,
+// delete) a user with targetRole. Moderators only manage regular users.
+func CanManage(actorRole, targetRole string) bool {
+	switch actorRole {
+	case models.RoleAdmin:
+		return true
+	case models.RoleModerator:
+		return targetRole == models.RoleUser
+	}
+	return false
+}
+
+// GetUserRole returns the user's current role.
+func GetUserRole(ctx context.Context, userID string) (string, error) {
+	var role string
+	err := database.Pool.QueryRow(ctx, `SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+	return role, err
+}
+
+// SetUserRole changes targetID's role on behalf of actorID.
+func SetUserRole(ctx context.Context, actorID, targetID, role string) error {
+	if !slices.Contains(models.Roles, role) {
+		return ErrUnknownRole
+	}
+	if actorID == targetID {
+		return ErrCannotChangeOwnRole
+	}
+
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1`, targetID, role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// noAdmin is the SQL condition for a deployment without any admin. Only
+// then do ADMIN_EMAILS get the role, so demoting one of them sticks.
+const noAdmin = `NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin' AND deleted_at IS NULL)`
+
+// BootstrapAdmins gives the admin role to existing users listed in
+// ADMIN_EMAILS while nobody has it, so a fresh deployment has someone to
+// hand out roles.
+func BootstrapAdmins(ctx context.Context) error {
+	emails := splitList(os.Getenv("ADMIN_EMAILS"))
+	if len(emails) == 0 {
+		return nil
+	}
+	_, err := database.Pool.Exec(ctx,
+		`UPDATE users SET role = 'admin', updated_at = NOW()
+		 WHERE lower(email) = ANY($1) AND deleted_at IS NULL AND `+noAdmin, emails)
+	return err
+}
+
+// isBootstrapAdmin reports whether email is listed in ADMIN_EMAILS.
+func isBootstrapAdmin(email string) bool {
+	return slices.Contains(splitList(os.Getenv("ADMIN_EMAILS")), strings.ToLower(email))
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/oauth-app/backend/internal/models"
+)
+
+func TestHasPermission(t *testing.T) {
+	if HasPermission(models.RoleUser, models.PermUsersRead) {
+		t.Error("users must not read other users")
+	}
+	if !HasPermission(models.RoleModerator, models.PermUsersSuspend) {
+		t.Error("moderators can suspend")
+	}
+	if HasPermission(models.RoleModerator, models.PermRolesManage) {
+		t.Error("moderators must not manage roles")
+	}
+	if HasPermission(models.RoleModerator, models.PermUsersImpersonate) {
+		t.Error("moderators must not impersonate")
+	}
+	if HasPermission(models.RoleModerator, models.PermSCIMManage) {
+		t.Error("moderators must not manage SCIM tenants")
+	}
+	if !HasPermission(models.RoleAdmin, models.PermRolesManage) {
+		t.Error("admins manage roles")
+	}
+	if HasPermission("", models.PermUsersRead) {
+		t.Error("unknown role granted a permission")
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	cases := []struct {
+		actor, target string
+		want          bool
+	}{
+		{models.RoleAdmin, models.RoleAdmin, true},
+		{models.RoleModerator, models.RoleUser, true},
+		{models.RoleModerator, models.RoleModerator, false},
+		{models.RoleModerator, models.RoleAdmin, false},
+		{models.RoleUser, models.RoleUser, false},
+	}
+	for _, tc := range cases {
+		if got := CanManage(tc.actor, tc.target); got != tc.want {
+			t.Errorf("CanManage(%s, %s) = %v, want %v", tc.actor, tc.target, got, tc.want)
+		}
+	}
+}
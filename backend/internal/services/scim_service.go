@@ -0,0 +1,581 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+	"github.com/oauth-app/backend/internal/providers"
+)
+
+// MaxSCIMPageSize caps the count parameter of a SCIM list request.
+const MaxSCIMPageSize = 200
+
+// SCIM errors; handlers map them to the RFC 7644 §3.12 scimType values.
+var (
+	ErrSCIMUniqueness    = errors.New("userName or externalId is already in use")
+	ErrSCIMInvalidFilter = errors.New("unsupported filter")
+	ErrSCIMInvalidValue  = errors.New("invalid attribute value")
+	ErrSCIMInvalidPath   = errors.New("unsupported attribute path")
+)
+
+// scimUserSelectFields are userSelectFields plus the external ID and
+// whether the account is active (not suspended or deactivated).
+var scimUserSelectFields = userSelectFields + fmt.Sprintf(`, scim_external_id,
+	NOT EXISTS (SELECT 1 FROM user_suspensions s WHERE s.user_id = users.id AND %s)`, activeSuspension)
+
+// withExtraColumns lets scanUser read rows that have more columns than
+// userSelectFields.
+type withExtraColumns struct {
+	row   pgx.Row
+	extra []any
+}
+
+func (r withExtraColumns) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.extra...)...)
+}
+
+func scanSCIMUser(row pgx.Row) (*models.SCIMUser, error) {
+	var (
+		externalID *string
+		active     bool
+	)
+	user, err := scanUser(withExtraColumns{row, []any{&externalID, &active}})
+	if err != nil {
+		return nil, err
+	}
+
+	u := &models.SCIMUser{
+		Schemas:     []string{models.SCIMSchemaUser},
+		ID:          user.ID,
+		UserName:    user.Email,
+		DisplayName: user.Name,
+		NickName:    user.Username,
+		Emails:      []models.SCIMMultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &models.SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     SCIMUserLocation(user.ID),
+		},
+	}
+	if externalID != nil {
+		u.ExternalID = *externalID
+	}
+	if user.Name != "" {
+		u.Name = &models.SCIMName{Formatted: user.Name}
+	}
+	if user.Phone != "" {
+		u.PhoneNumbers = []models.SCIMMultiValue{{Value: user.Phone, Type: "work", Primary: true}}
+	}
+	if user.Image != "" {
+		u.Photos = []models.SCIMMultiValue{{Value: user.Image, Type: "photo", Primary: true}}
+	}
+	return u, nil
+}
+
+// SCIMUserLocation is the URL of a user resource.
+func SCIMUserLocation(userID string) string {
+	return Issuer() + "/scim/v2/Users/" + userID
+}
+
+// scimAttributes are the parts of a SCIM user stored here. A nil Active
+// leaves the account's state alone.
+type scimAttributes struct {
+	Email      string
+	Name       string
+	Phone      string
+	Image      string
+	ExternalID string
+	Active     *bool
+}
+
+// scimUserAttributes maps a SCIM user onto our columns. The primary email
+// is the account's email; without one userName must be an email address.
+func scimUserAttributes(u models.SCIMUser) scimAttributes {
+	attrs := scimAttributes{
+		Email:      primaryValue(u.Emails),
+		Phone:      primaryValue(u.PhoneNumbers),
+		Image:      primaryValue(u.Photos),
+		ExternalID: u.ExternalID,
+		Active:     u.Active,
+	}
+	if attrs.Email == "" {
+		attrs.Email = u.UserName
+	}
+
+	switch {
+	case u.Name != nil && u.Name.Formatted != "":
+		attrs.Name = u.Name.Formatted
+	case u.DisplayName != "":
+		attrs.Name = u.DisplayName
+	case u.Name != nil:
+		attrs.Name = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+	return attrs
+}
+
+func (a scimAttributes) validate() (scimAttributes, error) {
+	addr, err := mail.ParseAddress(a.Email)
+	if err != nil || addr.Address != a.Email {
+		return a, fmt.Errorf("%w: userName must be an email address", ErrSCIMInvalidValue)
+	}
+	a.Email = strings.ToLower(a.Email)
+	if len(a.Name) > 255 || len(a.Phone) > 50 {
+		return a, fmt.Errorf("%w: name or phone number too long", ErrSCIMInvalidValue)
+	}
+	return a, nil
+}
+
+// primaryValue returns the primary entry of a multi-valued attribute, or
+// the first one.
+func primaryValue(values []models.SCIMMultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+// GetSCIMUser returns a user provisioned by the tenant.
+func GetSCIMUser(ctx context.Context, tenant *models.SCIMTenant, userID string) (*models.SCIMUser, error) {
+	if !isUUID(userID) {
+		return nil, ErrUserNotFound
+	}
+	u, err := scanSCIMUser(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM users
+		 WHERE id = $1 AND scim_tenant_id = $2 AND deleted_at IS NULL`, scimUserSelectFields),
+		userID, tenant.ID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+// ListSCIMUsers lists the tenant's users matching filter, oldest first.
+// startIndex is 1-based (RFC 7644 §3.4.2.4).
+func ListSCIMUsers(ctx context.Context, tenant *models.SCIMTenant, filter string, startIndex, count int) (*models.SCIMListResponse, error) {
+	column, value, err := parseSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	where := "WHERE scim_tenant_id = $1 AND deleted_at IS NULL"
+	args := []any{tenant.ID}
+	if column != "" {
+		where += fmt.Sprintf(" AND %s = $2", column)
+		args = append(args, value)
+	}
+
+	list := &models.SCIMListResponse{
+		Schemas:    []string{models.SCIMSchemaListResponse},
+		StartIndex: startIndex,
+		Resources:  []models.SCIMUser{},
+	}
+	// No user has a malformed id
+	if column == "id" && !isUUID(value) {
+		return list, nil
+	}
+	if err := database.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM users `+where, args...).Scan(&list.TotalResults); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.Pool.Query(ctx,
+		fmt.Sprintf(`SELECT %s FROM users %s ORDER BY created_at, id LIMIT %d OFFSET %d`,
+			scimUserSelectFields, where, count, startIndex-1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanSCIMUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		list.Resources = append(list.Resources, *u)
+	}
+	list.ItemsPerPage = len(list.Resources)
+	return list, rows.Err()
+}
+
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*([a-z.]+(?:\[[^\]]*\])?(?:\.value)?)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseSCIMFilter supports the equality filters directories use to look
+// users up: userName, emails(.value), externalId and id, each compared
+// with eq. It returns the column to compare and the value.
+func parseSCIMFilter(filter string) (string, string, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", "", nil
+	}
+	m := scimFilterPattern.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", fmt.Errorf("%w %q", ErrSCIMInvalidFilter, filter)
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(m[2]), &value); err != nil {
+		return "", "", fmt.Errorf("%w %q", ErrSCIMInvalidFilter, filter)
+	}
+
+	switch normalizeSCIMPath(m[1]) {
+	case "username", "emails", "emails.value":
+		return "email", strings.ToLower(value), nil
+	case "externalid":
+		return "scim_external_id", value, nil
+	case "id":
+		return "id", value, nil
+	default:
+		return "", "", fmt.Errorf("%w: cannot filter by %s", ErrSCIMInvalidFilter, m[1])
+	}
+}
+
+// normalizeSCIMPath lower-cases an attribute path and drops value
+// filters, so `emails[type eq "work"].value` becomes "emails.value".
+func normalizeSCIMPath(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	path = strings.TrimPrefix(path, strings.ToLower(models.SCIMSchemaUser)+":")
+	if start := strings.Index(path, "["); start >= 0 {
+		if end := strings.Index(path[start:], "]"); end >= 0 {
+			path = path[:start] + path[start+end+1:]
+		}
+	}
+	return path
+}
+
+// CreateSCIMUser provisions an account for the tenant. It has no login
+// identity until the user first signs in with a verified email matching
+// the account (see ClaimProvisionedUser).
+func CreateSCIMUser(ctx context.Context, tenant *models.SCIMTenant, u models.SCIMUser) (*models.SCIMUser, error) {
+	attrs, err := scimUserAttributes(u).validate()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID string
+	err = tx.QueryRow(ctx,
+		`INSERT INTO users (name, email, image, phone, username, login_count, scim_tenant_id, scim_external_id)
+		 VALUES ($1, $2, $3, $4, $5, 0, $6, NULLIF($7, ''))
+		 RETURNING id`,
+		attrs.Name, attrs.Email, attrs.Image, attrs.Phone, uniqueUsername(ctx, attrs.Name),
+		tenant.ID, attrs.ExternalID).Scan(&userID)
+	if err != nil {
+		if isUniqueViolation(err, "users_email_key") || isUniqueViolation(err, "idx_users_scim_external_id") {
+			return nil, ErrSCIMUniqueness
+		}
+		return nil, err
+	}
+
+	if attrs.Active != nil && !*attrs.Active {
+		if _, err := setSCIMActive(ctx, tx, tenant, userID, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return GetSCIMUser(ctx, tenant, userID)
+}
+
+// ReplaceSCIMUser overwrites a user's attributes (PUT).
+func ReplaceSCIMUser(ctx context.Context, tenant *models.SCIMTenant, userID string, u models.SCIMUser) (*models.SCIMUser, error) {
+	return updateSCIMUser(ctx, tenant, userID, scimUserAttributes(u))
+}
+
+// PatchSCIMUser applies PATCH operations to current, as returned by
+// GetSCIMUser.
+func PatchSCIMUser(ctx context.Context, tenant *models.SCIMTenant, current *models.SCIMUser, ops []models.SCIMPatchOperation) (*models.SCIMUser, error) {
+	attrs, err := applySCIMPatch(scimUserAttributes(*current), ops)
+	if err != nil {
+		return nil, err
+	}
+	return updateSCIMUser(ctx, tenant, current.ID, attrs)
+}
+
+func updateSCIMUser(ctx context.Context, tenant *models.SCIMTenant, userID string, attrs scimAttributes) (*models.SCIMUser, error) {
+	if !isUUID(userID) {
+		return nil, ErrUserNotFound
+	}
+	attrs, err := attrs.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE users SET name = $3, email = $4, image = $5, phone = $6, scim_external_id = NULLIF($7, ''), updated_at = NOW()
+		 WHERE id = $1 AND scim_tenant_id = $2 AND deleted_at IS NULL`,
+		userID, tenant.ID, attrs.Name, attrs.Email, attrs.Image, attrs.Phone, attrs.ExternalID)
+	if err != nil {
+		if isUniqueViolation(err, "users_email_key") || isUniqueViolation(err, "idx_users_scim_external_id") {
+			return nil, ErrSCIMUniqueness
+		}
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	if attrs.Active != nil {
+		if _, err := setSCIMActive(ctx, tx, tenant, userID, *attrs.Active); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return GetSCIMUser(ctx, tenant, userID)
+}
+
+// setSCIMActive deactivates a user (a suspension of kind deactivation,
+// signing them out everywhere) or lifts the deactivation. Suspensions
+// and bans set by administrators are left alone. It reports whether
+// anything changed.
+func setSCIMActive(ctx context.Context, tx pgx.Tx, tenant *models.SCIMTenant, userID string, active bool) (bool, error) {
+	if active {
+		tag, err := tx.Exec(ctx,
+			fmt.Sprintf(`UPDATE user_suspensions SET lifted_at = NOW()
+			 WHERE user_id = $1 AND kind = $2 AND %s`, activeSuspension),
+			userID, models.SuspensionKindDeactivation)
+		return tag.RowsAffected() > 0, err
+	}
+
+	if err := closeExpiredSuspensions(ctx, tx, userID); err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO user_suspensions (user_id, kind, reason) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id) WHERE lifted_at IS NULL DO NOTHING`,
+		userID, models.SuspensionKindDeactivation, fmt.Sprintf("Deactivated by %s", tenant.Name))
+	if err != nil {
+		return false, err
+	}
+	// Already suspended users are signed out too, in case they were not
+	return tag.RowsAffected() > 0, revokeUserCredentials(ctx, tx, userID)
+}
+
+// DeleteSCIMUser deactivates the user and schedules the account for
+// deletion like a user-initiated one.
+func DeleteSCIMUser(ctx context.Context, tenant *models.SCIMTenant, userID string) error {
+	if !isUUID(userID) {
+		return ErrUserNotFound
+	}
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	err = tx.QueryRow(ctx,
+		`SELECT id FROM users WHERE id = $1 AND scim_tenant_id = $2 AND deleted_at IS NULL FOR UPDATE`,
+		userID, tenant.ID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	// Restoring the account on the next login must not let the user back in
+	if _, err := setSCIMActive(ctx, tx, tenant, id, false); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	_, err = SoftDeleteUser(ctx, id)
+	return err
+}
+
+// applySCIMPatch applies PATCH operations (RFC 7644 §3.5.2) to attrs.
+// Operations without a path carry an object of attributes to set.
+func applySCIMPatch(attrs scimAttributes, ops []models.SCIMPatchOperation) (scimAttributes, error) {
+	for _, op := range ops {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path != "" {
+				if err := attrs.set(op.Path, op.Value); err != nil {
+					return attrs, err
+				}
+				continue
+			}
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return attrs, fmt.Errorf("%w: value must be an object when path is omitted", ErrSCIMInvalidValue)
+			}
+			for path, value := range values {
+				if path == "schemas" {
+					continue
+				}
+				if err := attrs.set(path, value); err != nil {
+					return attrs, err
+				}
+			}
+		case "remove":
+			if err := attrs.set(op.Path, nil); err != nil {
+				return attrs, err
+			}
+		default:
+			return attrs, fmt.Errorf("%w: unknown op %q", ErrSCIMInvalidValue, op.Op)
+		}
+	}
+	return attrs, nil
+}
+
+// set assigns one attribute from a PATCH value; a nil value removes it.
+func (a *scimAttributes) set(path string, value json.RawMessage) error {
+	remove := value == nil
+	p := normalizeSCIMPath(path)
+	switch p {
+	case "active":
+		if remove {
+			return fmt.Errorf("%w: active cannot be removed", ErrSCIMInvalidValue)
+		}
+		active, err := decodeSCIMBool(value)
+		if err != nil {
+			return err
+		}
+		a.Active = &active
+		return nil
+	case "username", "emails", "emails.value":
+		if remove {
+			return fmt.Errorf("%w: %s cannot be removed", ErrSCIMInvalidValue, path)
+		}
+		email, err := decodeSCIMValue(value)
+		if err != nil {
+			return err
+		}
+		a.Email = email
+		return nil
+	case "name":
+		if remove {
+			a.Name = ""
+			return nil
+		}
+		var name models.SCIMName
+		if err := json.Unmarshal(value, &name); err != nil {
+			return fmt.Errorf("%w: name must be an object", ErrSCIMInvalidValue)
+		}
+		a.Name = scimUserAttributes(models.SCIMUser{Name: &name}).Name
+		return nil
+	case "name.givenname", "name.familyname":
+		// Only the full name is stored; directories send it as well
+		return nil
+	}
+
+	var target *string
+	switch p {
+	case "displayname", "name.formatted":
+		target = &a.Name
+	case "phonenumbers", "phonenumbers.value":
+		target = &a.Phone
+	case "photos", "photos.value":
+		target = &a.Image
+	case "externalid":
+		target = &a.ExternalID
+	default:
+		return fmt.Errorf("%w %q", ErrSCIMInvalidPath, path)
+	}
+	if remove {
+		*target = ""
+		return nil
+	}
+	v, err := decodeSCIMValue(value)
+	if err != nil {
+		return err
+	}
+	*target = v
+	return nil
+}
+
+// decodeSCIMValue reads a string, or the primary value of a multi-valued
+// attribute.
+func decodeSCIMValue(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, nil
+	}
+	var values []models.SCIMMultiValue
+	if err := json.Unmarshal(value, &values); err == nil {
+		return primaryValue(values), nil
+	}
+	var single models.SCIMMultiValue
+	if err := json.Unmarshal(value, &single); err == nil {
+		return single.Value, nil
+	}
+	return "", fmt.Errorf("%w: expected a string", ErrSCIMInvalidValue)
+}
+
+// decodeSCIMBool accepts true/false as JSON booleans or strings; some
+// directories send "False".
+func decodeSCIMBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: expected a boolean", ErrSCIMInvalidValue)
+}
+
+// ClaimProvisionedUser attaches a login identity to an account a SCIM
+// tenant provisioned for the same verified email, the first time its
+// owner signs in. It returns ErrUserNotFound if there is no such account.
+func ClaimProvisionedUser(ctx context.Context, profile *providers.Profile) (*models.User, error) {
+	if !profile.EmailVerified || profile.Email == "" {
+		return nil, ErrUserNotFound
+	}
+
+	user, err := scanUser(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM users
+		 WHERE email = $1 AND scim_tenant_id IS NOT NULL
+		   AND NOT EXISTS (SELECT 1 FROM user_identities i WHERE i.user_id = users.id)`, userSelectFields),
+		strings.ToLower(profile.Email)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if _, err := LinkIdentity(ctx, user.ID, profile.Provider, profile.Subject, profile.Email); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
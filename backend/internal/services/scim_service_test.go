@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/oauth-app/backend/internal/models"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	cases := []struct {
+		filter, column, value string
+	}{
+		{``, "", ""},
+		{`userName eq "Ann@Example.com"`, "email", "ann@example.com"},
+		{`emails[type eq "work"].value eq "ann@example.com"`, "email", "ann@example.com"},
+		{`externalId EQ "00u1\"x"`, "scim_external_id", `00u1"x`},
+		{`id eq "abc"`, "id", "abc"},
+	}
+	for _, tc := range cases {
+		column, value, err := parseSCIMFilter(tc.filter)
+		if err != nil || column != tc.column || value != tc.value {
+			t.Errorf("parseSCIMFilter(%q) = %q, %q, %v; want %q, %q", tc.filter, column, value, err, tc.column, tc.value)
+		}
+	}
+
+	for _, filter := range []string{`userName sw "ann"`, `title eq "x"`, `userName eq "a" or userName eq "b"`} {
+		if _, _, err := parseSCIMFilter(filter); !errors.Is(err, ErrSCIMInvalidFilter) {
+			t.Errorf("parseSCIMFilter(%q) error = %v, want ErrSCIMInvalidFilter", filter, err)
+		}
+	}
+}
+
+func TestSCIMUserAttributes(t *testing.T) {
+	attrs, err := scimUserAttributes(models.SCIMUser{
+		UserName: "ann@corp.example",
+		Name:     &models.SCIMName{GivenName: "Ann", FamilyName: "Lee"},
+		Emails: []models.SCIMMultiValue{
+			{Value: "other@corp.example"},
+			{Value: "Ann.Lee@Corp.example", Primary: true},
+		},
+	}).validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Email != "ann.lee@corp.example" || attrs.Name != "Ann Lee" || attrs.Active != nil {
+		t.Errorf("attributes = %+v", attrs)
+	}
+
+	if _, err := scimUserAttributes(models.SCIMUser{UserName: "ann"}).validate(); !errors.Is(err, ErrSCIMInvalidValue) {
+		t.Errorf("userName without an email: error = %v, want ErrSCIMInvalidValue", err)
+	}
+}
+
+func TestApplySCIMPatch(t *testing.T) {
+	active := true
+	attrs := scimAttributes{Email: "ann@corp.example", Name: "Ann", Phone: "123", Active: &active}
+
+	ops := []models.SCIMPatchOperation{
+		{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)},
+		{Op: "replace", Path: `emails[type eq "work"].value`, Value: json.RawMessage(`"ann.lee@corp.example"`)},
+		{Op: "add", Value: json.RawMessage(`{"displayName": "Ann Lee", "externalId": "00u1"}`)},
+		{Op: "remove", Path: "phoneNumbers"},
+	}
+	got, err := applySCIMPatch(attrs, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.Active || got.Email != "ann.lee@corp.example" || got.Name != "Ann Lee" || got.ExternalID != "00u1" || got.Phone != "" {
+		t.Errorf("patched = %+v", got)
+	}
+
+	for _, op := range []models.SCIMPatchOperation{
+		{Op: "replace", Path: "title", Value: json.RawMessage(`"CEO"`)},
+		{Op: "remove", Path: "userName"},
+		{Op: "move", Path: "active"},
+	} {
+		if _, err := applySCIMPatch(attrs, []models.SCIMPatchOperation{op}); err == nil {
+			t.Errorf("applySCIMPatch(%+v) succeeded", op)
+		}
+	}
+}
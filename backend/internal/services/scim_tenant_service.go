@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+// scimTokenPrefix tells SCIM tokens apart from personal access tokens and
+// JWTs.
+const scimTokenPrefix = "scim_"
+
+var ErrSCIMTenantNotFound = errors.New("SCIM tenant not found")
+
+var scimTenantSelectFields = `id, name, token_prefix, created_by, created_at, last_used_at, revoked_at`
+
+func scanSCIMTenant(row pgx.Row) (*models.SCIMTenant, error) {
+	var t models.SCIMTenant
+	err := row.Scan(&t.ID, &t.Name, &t.TokenPrefix, &t.CreatedBy, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateSCIMTenant registers a directory and returns its bearer token,
+// which is only available at creation time.
+func CreateSCIMTenant(ctx context.Context, createdBy string, req models.CreateSCIMTenantRequest) (*models.SCIMTenant, string, error) {
+	random, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	token := scimTokenPrefix + random
+
+	tenant, err := scanSCIMTenant(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`INSERT INTO scim_tenants (name, token_hash, token_prefix, created_by)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING %s`, scimTenantSelectFields),
+		req.Name, hashToken(token), token[:len(scimTokenPrefix)+6], createdBy))
+	if err != nil {
+		return nil, "", err
+	}
+	return tenant, token, nil
+}
+
+// GetSCIMTenants lists all tenants, newest first.
+func GetSCIMTenants(ctx context.Context) ([]models.SCIMTenant, error) {
+	rows, err := database.Pool.Query(ctx,
+		fmt.Sprintf(`SELECT %s FROM scim_tenants ORDER BY created_at DESC`, scimTenantSelectFields))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []models.SCIMTenant
+	for rows.Next() {
+		t, err := scanSCIMTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, *t)
+	}
+	return tenants, rows.Err()
+}
+
+// RevokeSCIMTenant disables a tenant's token. Users it provisioned are
+// kept and can still sign in.
+func RevokeSCIMTenant(ctx context.Context, tenantID string) error {
+	if !isUUID(tenantID) {
+		return ErrSCIMTenantNotFound
+	}
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE scim_tenants SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, tenantID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSCIMTenantNotFound
+	}
+	return nil
+}
+
+// ValidateSCIMToken returns the active tenant a bearer token belongs to
+// and records that it was used.
+func ValidateSCIMToken(ctx context.Context, token string) (*models.SCIMTenant, error) {
+	if !strings.HasPrefix(token, scimTokenPrefix) {
+		return nil, ErrSCIMTenantNotFound
+	}
+	tenant, err := scanSCIMTenant(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`UPDATE scim_tenants SET last_used_at = NOW()
+		 WHERE token_hash = $1 AND revoked_at IS NULL
+		 RETURNING %s`, scimTenantSelectFields), hashToken(token)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSCIMTenantNotFound
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+const SessionTTL = 7 * 24 * time.Hour
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// CreateSession starts a session. A session created with mfaPending cannot
+// be used until CompleteMFA upgrades it.
+func CreateSession(ctx context.Context, userID, ipAddress, userAgent string, mfaPending bool) (*models.Session, error) {
+	var s models.Session
+	err := database.Pool.QueryRow(ctx,
+		`INSERT INTO sessions (user_id, ip_address, user_agent, device, expires_at, mfa_pending)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, user_id, ip_address, user_agent, device, created_at, last_seen_at, expires_at`,
+		userID, ipAddress, userAgent, deviceFromUserAgent(userAgent), time.Now().Add(SessionTTL), mfaPending).
+		Scan(&s.ID, &s.UserID, &s.IPAddress, &s.UserAgent, &s.Device, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// MarkSecondFactor records that the session was started with a second
+// factor, e.g. a user-verified passkey login.
+func MarkSecondFactor(ctx context.Context, sessionID string) error {
+	_, err := database.Pool.Exec(ctx, `UPDATE sessions SET second_factor_at = NOW() WHERE id = $1`, sessionID)
+	return err
+}
+
+// HasRecentSecondFactor reports whether the session presented a second
+// factor, at login or in a step-up, within ReauthMaxAge.
+func HasRecentSecondFactor(ctx context.Context, userID, sessionID string) (bool, error) {
+	if !isUUID(sessionID) {
+		return false, nil
+	}
+	var recent bool
+	err := database.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sessions
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND second_factor_at > $3)`,
+		sessionID, userID, time.Now().Add(-ReauthMaxAge)).Scan(&recent)
+	return recent, err
+}
+
+// TouchSession checks that the session is still active for userID and
+// records the request as its latest activity.
+func TouchSession(ctx context.Context, sessionID, userID, ipAddress string) error {
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE sessions SET last_seen_at = NOW(), ip_address = $3
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW() AND NOT mfa_pending`,
+		sessionID, userID, ipAddress)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// IsSessionActive reports whether the session is live for userID, without
+// recording activity (see TouchSession).
+func IsSessionActive(ctx context.Context, sessionID, userID string) (bool, error) {
+	if !isUUID(sessionID) || !isUUID(userID) {
+		return false, nil
+	}
+	var active bool
+	err := database.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sessions
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW() AND NOT mfa_pending)`,
+		sessionID, userID).Scan(&active)
+	return active, err
+}
+
+func GetActiveSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	rows, err := database.Pool.Query(ctx,
+		`SELECT id, user_id, ip_address, user_agent, device, created_at, last_seen_at, expires_at, impersonator_id
+		 FROM sessions
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND NOT mfa_pending
+		 ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.IPAddress, &s.UserAgent, &s.Device,
+			&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.ImpersonatorID); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+func RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if !isUUID(sessionID) {
+		return ErrSessionNotFound
+	}
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE sessions SET revoked_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DiscardSession deletes a session that never became usable (an
+// mfa_pending session handed over to a native app login code).
+func DiscardSession(ctx context.Context, sessionID string) error {
+	_, err := database.Pool.Exec(ctx, `DELETE FROM sessions WHERE id = $1 AND mfa_pending`, sessionID)
+	return err
+}
+
+// RevokeAllSessions revokes every active session of the user ("log out everywhere").
+func RevokeAllSessions(ctx context.Context, userID string) (int64, error) {
+	tag, err := database.Pool.Exec(ctx,
+		`UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// deviceFromUserAgent produces a short label like "Chrome on macOS".
+func deviceFromUserAgent(ua string) string {
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	platform := "Unknown OS"
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		platform = "iOS"
+	case strings.Contains(ua, "Android"):
+		platform = "Android"
+	case strings.Contains(ua, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(ua, "Windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "Linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+const (
+	// SuspensionNoticeCookie lets a suspended user who tries to sign in
+	// read why, without a session.
+	SuspensionNoticeCookie = "suspension_notice"
+	SuspensionNoticeTTL    = 15 * time.Minute
+
+	suspensionNoticeAudience = "suspension"
+)
+
+var (
+	ErrAlreadySuspended = errors.New("user is already suspended")
+	ErrNotSuspended     = errors.New("user is not suspended")
+)
+
+const suspensionSelectFields = `id, user_id, kind, reason, suspended_by, created_at, expires_at, lifted_at`
+
+// activeSuspension is the SQL condition for a suspension that is in force.
+const activeSuspension = `lifted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+
+func scanSuspension(row pgx.Row) (*models.Suspension, error) {
+	var s models.Suspension
+	err := row.Scan(&s.ID, &s.UserID, &s.Kind, &s.Reason, &s.SuspendedBy, &s.CreatedAt, &s.ExpiresAt, &s.LiftedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetActiveSuspension returns the suspension or ban in force for the user, or nil.
+func GetActiveSuspension(ctx context.Context, userID string) (*models.Suspension, error) {
+	s, err := scanSuspension(database.Pool.QueryRow(ctx,
+		fmt.Sprintf(`SELECT %s FROM user_suspensions WHERE user_id = $1 AND %s`, suspensionSelectFields, activeSuspension),
+		userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSuspensionNotice describes the suspension or ban in force for the
+// user to the user themselves, or returns nil.
+func GetSuspensionNotice(ctx context.Context, userID string) (*models.SuspensionNotice, error) {
+	s, err := GetActiveSuspension(ctx, userID)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &models.SuspensionNotice{Kind: s.Kind, Reason: s.Reason, CreatedAt: s.CreatedAt, ExpiresAt: s.ExpiresAt}, nil
+}
+
+// SuspendUser locks the user out: their sessions and personal access
+// tokens are revoked and new logins are refused until the suspension
+// expires or is lifted. Bans never expire.
+func SuspendUser(ctx context.Context, actorID, userID string, req models.SuspendUserRequest) (*models.Suspension, error) {
+	kind := req.Kind
+	if kind == "" {
+		kind = models.SuspensionKindSuspension
+	}
+	var expiresAt *time.Time
+	if kind == models.SuspensionKindSuspension && req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := closeExpiredSuspensions(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	s, err := scanSuspension(tx.QueryRow(ctx,
+		fmt.Sprintf(`INSERT INTO user_suspensions (user_id, kind, reason, suspended_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING %s`, suspensionSelectFields), userID, kind, req.Reason, actorID, expiresAt))
+	if err != nil {
+		if isUniqueViolation(err, "idx_user_suspensions_active") {
+			return nil, ErrAlreadySuspended
+		}
+		return nil, err
+	}
+
+	if err := revokeUserCredentials(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// closeExpiredSuspensions closes suspensions that ran out so a new one
+// can be the open row.
+func closeExpiredSuspensions(ctx context.Context, tx pgx.Tx, userID string) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE user_suspensions SET lifted_at = expires_at
+		 WHERE user_id = $1 AND lifted_at IS NULL AND expires_at <= NOW()`, userID)
+	return err
+}
+
+// LiftSuspension ends the suspension or ban in force for the user.
+func LiftSuspension(ctx context.Context, actorID, userID string) error {
+	tag, err := database.Pool.Exec(ctx,
+		fmt.Sprintf(`UPDATE user_suspensions SET lifted_at = NOW(), lifted_by = $2
+		 WHERE user_id = $1 AND %s`, activeSuspension), userID, actorID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotSuspended
+	}
+	return nil
+}
+
+// RevokeSuspendedUserSessions signs out a suspended user found still
+// holding a live session or token.
+func RevokeSuspendedUserSessions(ctx context.Context, userID string) error {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := revokeUserCredentials(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func revokeUserCredentials(ctx context.Context, tx pgx.Tx, userID string) error {
+	if _, err := tx.Exec(ctx,
+		`UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+func GenerateSuspensionNoticeToken(userID string) (string, error) {
+	return signUserToken(userID, suspensionNoticeAudience, SuspensionNoticeTTL)
+}
+
+// ValidateSuspensionNoticeToken returns the user ID the token was issued for.
+func ValidateSuspensionNoticeToken(tokenString string) (string, error) {
+	return parseUserToken(tokenString, suspensionNoticeAudience)
+}
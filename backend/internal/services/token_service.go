@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+const AccessTokenTTL = 15 * time.Minute
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// IssueTokens mints an access token and the first refresh token of a session.
+func IssueTokens(ctx context.Context, user *models.User, sessionID string) (string, string, error) {
+	var authTime time.Time
+	err := database.Pool.QueryRow(ctx,
+		`SELECT authenticated_at FROM sessions WHERE id = $1`, sessionID).Scan(&authTime)
+	if err != nil {
+		return "", "", err
+	}
+
+	access, err := GenerateJWT(user, sessionID, authTime)
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = database.Pool.Exec(ctx,
+		`INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		sessionID, hashToken(refresh), time.Now().Add(SessionTTL))
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// RefreshTokens redeems a refresh token: it is marked used and replaced by
+// a new one in the same family, and a fresh access token is issued. If an
+// already-used token is presented again the whole family (session) is
+// revoked, however soon it comes back: clients must not refresh in
+// parallel.
+func RefreshTokens(ctx context.Context, rawRefresh string) (string, string, error) {
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		tokenID, sessionID, userID string
+		expiresAt, authTime        time.Time
+		usedAt                     *time.Time
+		sessionActive              bool
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT t.id, t.session_id, s.user_id, t.expires_at, t.used_at, s.authenticated_at,
+		        s.revoked_at IS NULL AND s.expires_at > NOW()
+		 FROM refresh_tokens t JOIN sessions s ON s.id = t.session_id
+		 WHERE t.token_hash = $1
+		 FOR UPDATE OF t`, hashToken(rawRefresh)).
+		Scan(&tokenID, &sessionID, &userID, &expiresAt, &usedAt, &authTime, &sessionActive)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", ErrInvalidRefreshToken
+		}
+		return "", "", err
+	}
+
+	if !sessionActive || time.Now().After(expiresAt) {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	if usedAt != nil {
+		// Reuse of a rotated token: assume it was stolen and kill the family
+		if _, err := tx.Exec(ctx,
+			`UPDATE sessions SET revoked_at = NOW() WHERE id = $1`, sessionID); err != nil {
+			return "", "", err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return "", "", err
+		}
+		_ = LogActivity(ctx, userID, "Refresh token reuse detected — session revoked")
+		return "", "", ErrRefreshTokenReused
+	}
+
+	newRefresh, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	newExpiry := time.Now().Add(SessionTTL)
+	if _, err := tx.Exec(ctx,
+		`UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, tokenID); err != nil {
+		return "", "", err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		sessionID, hashToken(newRefresh), newExpiry); err != nil {
+		return "", "", err
+	}
+	// Sliding expiry: an actively used session stays alive
+	if _, err := tx.Exec(ctx,
+		`UPDATE sessions SET expires_at = $2, last_seen_at = NOW() WHERE id = $1`,
+		sessionID, newExpiry); err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", "", err
+	}
+
+	user, err := FindUserByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	access, err := GenerateJWT(user, sessionID, authTime)
+	if err != nil {
+		return "", "", err
+	}
+	return access, newRefresh, nil
+}
+
+// ReauthenticateSession records a successful step-up authentication and
+// returns an access token carrying the new auth_time. secondFactor marks a
+// step-up with a code or passkey (see HasRecentSecondFactor). The refresh
+// token is unchanged.
+func ReauthenticateSession(ctx context.Context, userID, sessionID string, secondFactor bool) (string, error) {
+	var authTime time.Time
+	err := database.Pool.QueryRow(ctx,
+		`UPDATE sessions SET authenticated_at = NOW(), last_seen_at = NOW(),
+		   second_factor_at = CASE WHEN $3 THEN NOW() ELSE second_factor_at END
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW() AND NOT mfa_pending
+		 RETURNING authenticated_at`, sessionID, userID, secondFactor).Scan(&authTime)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrSessionNotFound
+		}
+		return "", err
+	}
+
+	user, err := FindUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return GenerateJWT(user, sessionID, authTime)
+}
+
+// RevokeSessionByRefreshToken revokes the session a refresh token belongs to.
+func RevokeSessionByRefreshToken(ctx context.Context, rawRefresh string) error {
+	_, err := database.Pool.Exec(ctx,
+		`UPDATE sessions SET revoked_at = NOW()
+		 WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1) AND revoked_at IS NULL`,
+		hashToken(rawRefresh))
+	return err
+}
+
+// hashToken returns the hex SHA-256 of an opaque token for storage at rest.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B test vectors (SHA-1 key), truncated to 6 digits.
+func TestValidateTOTPVectors(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, v := range vectors {
+		step, ok := ValidateTOTP(secret, v.code, time.Unix(v.unix, 0))
+		if !ok {
+			t.Errorf("t=%d: code %s rejected", v.unix, v.code)
+			continue
+		}
+		if step != v.unix/totpPeriod {
+			t.Errorf("t=%d: step = %d, want %d", v.unix, step, v.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+
+	if _, ok := ValidateTOTP(secret, totpCode(key, uint64(step-1)), now); !ok {
+		t.Error("previous step should be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, totpCode(key, uint64(step+1)), now); !ok {
+		t.Error("next step should be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, totpCode(key, uint64(step-3)), now); ok {
+		t.Error("code three steps old should be rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("short code should be rejected")
+	}
+}
@@ -2,36 +2,39 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/oauth-app/backend/internal/database"
 	"github.com/oauth-app/backend/internal/models"
 )
 
-var userSelectFields = `id, google_id, name, email, image, username, bio, phone, location,
-	is_public, login_count, last_login_at, created_at, updated_at`
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrEmailInUse means another account already has the email; the user has
+// to sign in to that account and link the new identity from there.
+var ErrEmailInUse = errors.New("email already belongs to another account")
+
+var userSelectFields = `id, name, email, image, username, bio, phone, location,
+	is_public, role, login_count, last_login_at, created_at, updated_at, invited_by, deleted_at, purge_after`
 
 func scanUser(row interface{ Scan(dest ...any) error }) (*models.User, error) {
 	var user models.User
 	err := row.Scan(
-		&user.ID, &user.GoogleID, &user.Name, &user.Email, &user.Image, &user.Username,
+		&user.ID, &user.Name, &user.Email, &user.Image, &user.Username,
 		&user.Bio, &user.Phone, &user.Location,
-		&user.IsPublic, &user.LoginCount, &user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt)
+		&user.IsPublic, &user.Role, &user.LoginCount, &user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt,
+		&user.InvitedBy, &user.DeletedAt, &user.PurgeAfter)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func FindUserByGoogleID(ctx context.Context, googleID string) (*models.User, error) {
-	row := database.Pool.QueryRow(ctx,
-		fmt.Sprintf(`SELECT %s FROM users WHERE google_id = $1`, userSelectFields), googleID)
-	return scanUser(row)
-}
-
 func FindUserByID(ctx context.Context, id string) (*models.User, error) {
 	row := database.Pool.QueryRow(ctx,
 		fmt.Sprintf(`SELECT %s FROM users WHERE id = $1`, userSelectFields), id)
@@ -44,7 +47,35 @@ func FindUserByUsername(ctx context.Context, username string) (*models.User, err
 	return scanUser(row)
 }
 
-func CreateUser(ctx context.Context, googleID, name, email, image string) (*models.User, error) {
+// createUser creates a user together with the provider identity they
+// signed in with. New accounts go through SignUp, which checks the
+// admission policy first.
+func createUser(ctx context.Context, tx pgx.Tx, provider, subject, name, email, image string) (*models.User, error) {
+	username := uniqueUsername(ctx, name)
+
+	row := tx.QueryRow(ctx,
+		fmt.Sprintf(`INSERT INTO users (name, email, image, username, login_count, last_login_at)
+		 VALUES ($1, $2, $3, $4, 1, NOW())
+		 RETURNING %s`, userSelectFields),
+		name, email, image, username)
+	user, err := scanUser(row)
+	if err != nil {
+		if isUniqueViolation(err, "users_email_key") {
+			return nil, ErrEmailInUse
+		}
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)`,
+		user.ID, provider, subject, email); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// uniqueUsername generates a username from name that is not taken yet.
+func uniqueUsername(ctx context.Context, name string) string {
 	username := generateUsername(name)
 
 	// Ensure uniqueness
@@ -55,13 +86,7 @@ func CreateUser(ctx context.Context, googleID, name, email, image string) (*mode
 		}
 		username = generateUsername(name)
 	}
-
-	row := database.Pool.QueryRow(ctx,
-		fmt.Sprintf(`INSERT INTO users (google_id, name, email, image, username, login_count, last_login_at)
-		 VALUES ($1, $2, $3, $4, $5, 1, NOW())
-		 RETURNING %s`, userSelectFields),
-		googleID, name, email, image, username)
-	return scanUser(row)
+	return username
 }
 
 func IncrementLoginCount(ctx context.Context, userID string) error {
@@ -71,6 +96,11 @@ func IncrementLoginCount(ctx context.Context, userID string) error {
 }
 
 func UpdateUser(ctx context.Context, userID string, req models.UpdateUserRequest) (*models.User, error) {
+	current, err := FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	query := "UPDATE users SET updated_at = NOW()"
 	args := []interface{}{}
 	argIdx := 1
@@ -104,11 +134,17 @@ func UpdateUser(ctx context.Context, userID string, req models.UpdateUserRequest
 	query += fmt.Sprintf(" WHERE id = $%d", argIdx)
 	args = append(args, userID)
 
-	_, err := database.Pool.Exec(ctx, query, args...)
-	if err != nil {
+	if _, err := database.Pool.Exec(ctx, query, args...); err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	// A name the user chose stops until_edited syncing from the provider
+	if req.Name != nil && *req.Name != current.Name {
+		if err := markProfileFieldEdited(ctx, userID, models.ProfileFieldName); err != nil {
+			return nil, err
+		}
+	}
+
 	return FindUserByID(ctx, userID)
 }
 
@@ -124,12 +160,6 @@ func UpdateUsername(ctx context.Context, userID, username string) error {
 	return err
 }
 
-// HardDeleteUser permanently removes user and all related data (CASCADE)
-func HardDeleteUser(ctx context.Context, userID string) error {
-	_, err := database.Pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
-	return err
-}
-
 func GetProfileViewCount(ctx context.Context, userID string) (int, error) {
 	var count int
 	err := database.Pool.QueryRow(ctx,
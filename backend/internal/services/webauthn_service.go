@@ -0,0 +1,465 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/jackc/pgx/v5"
+	"github.com/oauth-app/backend/internal/database"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+// WebAuthn ceremonies a stored challenge can be redeemed for
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login" // passwordless, discoverable credential
+	ceremonyMFA          = "mfa"   // second factor for a known user
+
+	webauthnChallengeTTL = 5 * time.Minute
+)
+
+var (
+	ErrPasskeyNotFound     = errors.New("passkey not found")
+	ErrInvalidChallenge    = errors.New("invalid or expired challenge")
+	ErrInvalidPasskey      = errors.New("passkey verification failed")
+	ErrPasskeyCloneWarning = errors.New("passkey signature counter went backwards")
+)
+
+var webAuthn *webauthn.WebAuthn
+
+// InitWebAuthn configures the relying party. The RP ID defaults to the
+// frontend's host name, since that is the origin the browser runs the
+// ceremonies on.
+func InitWebAuthn() error {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		u, err := url.Parse(frontendURL)
+		if err != nil {
+			return fmt.Errorf("parse FRONTEND_URL: %w", err)
+		}
+		rpID = u.Hostname()
+	}
+
+	origins := []string{frontendURL}
+	if v := os.Getenv("WEBAUTHN_ORIGINS"); v != "" {
+		origins = strings.Split(v, ",")
+	}
+
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "OAuth App"
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+	})
+	if err != nil {
+		return err
+	}
+	webAuthn = w
+	return nil
+}
+
+// webauthnUser adapts a user and their passkeys to webauthn.User.
+type webauthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte                         { return userHandle(u.user.ID) }
+func (u *webauthnUser) WebAuthnName() string                       { return u.user.Email }
+func (u *webauthnUser) WebAuthnDisplayName() string                { return u.user.Name }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// userHandle is the 16 raw bytes of the user's UUID.
+func userHandle(userID string) []byte {
+	b, _ := hex.DecodeString(strings.ReplaceAll(userID, "-", ""))
+	return b
+}
+
+func userIDFromHandle(handle []byte) string {
+	h := hex.EncodeToString(handle)
+	if len(h) != 32 {
+		return ""
+	}
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func loadWebAuthnUser(ctx context.Context, userID string) (*webauthnUser, error) {
+	user, err := FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.Pool.Query(ctx,
+		`SELECT credential_id, public_key, attestation_type, transports, aaguid, sign_count, backup_eligible, backup_state
+		 FROM webauthn_credentials WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	u := &webauthnUser{user: user}
+	for rows.Next() {
+		var (
+			c          webauthn.Credential
+			transports []string
+			signCount  int64
+		)
+		if err := rows.Scan(&c.ID, &c.PublicKey, &c.AttestationType, &transports, &c.Authenticator.AAGUID,
+			&signCount, &c.Flags.BackupEligible, &c.Flags.BackupState); err != nil {
+			return nil, err
+		}
+		for _, t := range transports {
+			c.Transport = append(c.Transport, protocol.AuthenticatorTransport(t))
+		}
+		c.Authenticator.SignCount = uint32(signCount)
+		u.credentials = append(u.credentials, c)
+	}
+	return u, rows.Err()
+}
+
+func HasPasskeys(ctx context.Context, userID string) (bool, error) {
+	var exists bool
+	err := database.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM webauthn_credentials WHERE user_id = $1)`, userID).Scan(&exists)
+	return exists, err
+}
+
+func GetPasskeys(ctx context.Context, userID string) ([]models.Passkey, error) {
+	rows, err := database.Pool.Query(ctx,
+		`SELECT id, name, backup_eligible, backup_state, created_at, last_used_at
+		 FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []models.Passkey
+	for rows.Next() {
+		var p models.Passkey
+		if err := rows.Scan(&p.ID, &p.Name, &p.BackupEligible, &p.BackupState, &p.CreatedAt, &p.LastUsedAt); err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+	return passkeys, nil
+}
+
+// DeletePasskey removes a passkey. Recovery codes only exist to back up a
+// second factor, so they go too once the user has none left.
+func DeletePasskey(ctx context.Context, userID, passkeyID string) error {
+	if !isUUID(passkeyID) {
+		return ErrPasskeyNotFound
+	}
+	tag, err := database.Pool.Exec(ctx,
+		`DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, passkeyID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeyNotFound
+	}
+
+	enabled, err := IsMFAEnabled(ctx, userID)
+	if err == nil && !enabled {
+		_, err = database.Pool.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	}
+	return err
+}
+
+// BeginPasskeyRegistration starts registering a new (discoverable) passkey
+// and returns the creation options for navigator.credentials.create().
+func BeginPasskeyRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, string, error) {
+	u, err := loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := webAuthn.BeginRegistration(u,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired))
+	if err != nil {
+		return nil, "", err
+	}
+
+	challengeID, err := saveChallenge(ctx, userID, ceremonyRegistration, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, challengeID, nil
+}
+
+// FinishPasskeyRegistration verifies the attestation and stores the
+// passkey. If this gives the user their first second factor, recovery codes
+// are generated and returned as well.
+func FinishPasskeyRegistration(ctx context.Context, userID, challengeID, name string, response []byte) (*models.Passkey, []string, error) {
+	session, err := takeChallenge(ctx, challengeID, ceremonyRegistration, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+	cred, err := webAuthn.CreateCredential(u, *session, parsed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var remainingCodes int
+	if err := tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).
+		Scan(&remainingCodes); err != nil {
+		return nil, nil, err
+	}
+
+	var p models.Passkey
+	err = tx.QueryRow(ctx,
+		`INSERT INTO webauthn_credentials
+		   (user_id, name, credential_id, public_key, attestation_type, transports, aaguid, sign_count, backup_eligible, backup_state)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id, name, backup_eligible, backup_state, created_at, last_used_at`,
+		userID, name, cred.ID, cred.PublicKey, cred.AttestationType, transports, cred.Authenticator.AAGUID,
+		int64(cred.Authenticator.SignCount), cred.Flags.BackupEligible, cred.Flags.BackupState).
+		Scan(&p.ID, &p.Name, &p.BackupEligible, &p.BackupState, &p.CreatedAt, &p.LastUsedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var codes []string
+	if remainingCodes == 0 {
+		if codes, err = replaceRecoveryCodes(ctx, tx, userID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	return &p, codes, nil
+}
+
+// BeginPasskeyLogin starts an assertion. With an empty userID it is a
+// passwordless login where the browser offers any discoverable passkey for
+// this site; otherwise it is a second-factor check limited to that user's
+// passkeys.
+func BeginPasskeyLogin(ctx context.Context, userID string) (*protocol.CredentialAssertion, string, error) {
+	var u *webauthnUser
+	if userID != "" {
+		var err error
+		if u, err = loadWebAuthnUser(ctx, userID); err != nil {
+			return nil, "", err
+		}
+		if len(u.credentials) == 0 {
+			return nil, "", ErrPasskeyNotFound
+		}
+	}
+
+	assertion, session, ceremony, err := beginAssertion(u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	challengeID, err := saveChallenge(ctx, userID, ceremony, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, challengeID, nil
+}
+
+// beginAssertion starts a passwordless login when u is nil, otherwise a
+// second-factor check for u, and returns the ceremony to store it under.
+// A passwordless login stands in for both factors, so the authenticator
+// must verify the user (PIN or biometric), not just their presence.
+func beginAssertion(u *webauthnUser) (*protocol.CredentialAssertion, *webauthn.SessionData, string, error) {
+	if u == nil {
+		assertion, session, err := webAuthn.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired))
+		return assertion, session, ceremonyLogin, err
+	}
+	assertion, session, err := webAuthn.BeginLogin(u)
+	return assertion, session, ceremonyMFA, err
+}
+
+// FinishPasskeyLogin verifies a passwordless assertion and returns the user
+// it belongs to.
+func FinishPasskeyLogin(ctx context.Context, challengeID string, response []byte) (string, error) {
+	session, err := takeChallenge(ctx, challengeID, ceremonyLogin, "")
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	// Fails unless the authenticator reports user verification, which the
+	// session requires
+	var userID string
+	cred, err := webAuthn.ValidateDiscoverableLogin(func(_, handle []byte) (webauthn.User, error) {
+		id := userIDFromHandle(handle)
+		if id == "" {
+			return nil, ErrInvalidPasskey
+		}
+		u, err := loadWebAuthnUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		userID = u.user.ID
+		return u, nil
+	}, *session, parsed)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	if err := recordPasskeyUse(ctx, cred); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// VerifyPasskey verifies a second-factor assertion for userID.
+func VerifyPasskey(ctx context.Context, userID, challengeID string, response []byte) error {
+	session, err := takeChallenge(ctx, challengeID, ceremonyMFA, userID)
+	if err != nil {
+		return err
+	}
+
+	u, err := loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+	cred, err := webAuthn.ValidateLogin(u, *session, parsed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+	return recordPasskeyUse(ctx, cred)
+}
+
+// recordPasskeyUse stores the new signature counter. A counter that did not
+// increase means the private key may have been cloned, so the login is
+// refused.
+func recordPasskeyUse(ctx context.Context, cred *webauthn.Credential) error {
+	if cred.Authenticator.CloneWarning {
+		return ErrPasskeyCloneWarning
+	}
+	_, err := database.Pool.Exec(ctx,
+		`UPDATE webauthn_credentials SET sign_count = $2, backup_state = $3, last_used_at = NOW()
+		 WHERE credential_id = $1`,
+		cred.ID, int64(cred.Authenticator.SignCount), cred.Flags.BackupState)
+	return err
+}
+
+func saveChallenge(ctx context.Context, userID, ceremony string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	var owner *string
+	if userID != "" {
+		owner = &userID
+	}
+
+	var id string
+	err = database.Pool.QueryRow(ctx,
+		`INSERT INTO webauthn_challenges (user_id, ceremony, session_data, expires_at)
+		 VALUES ($1, $2, $3, $4) RETURNING id`,
+		owner, ceremony, data, time.Now().Add(webauthnChallengeTTL)).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	// Opportunistic cleanup of abandoned ceremonies
+	_, _ = database.Pool.Exec(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < NOW()`)
+	return id, nil
+}
+
+// takeChallenge consumes a stored challenge. userID must match the user
+// the ceremony was started for ("" for passwordless logins).
+func takeChallenge(ctx context.Context, challengeID, ceremony, userID string) (*webauthn.SessionData, error) {
+	if !isUUID(challengeID) {
+		return nil, ErrInvalidChallenge
+	}
+	var (
+		owner *string
+		data  []byte
+	)
+	err := database.Pool.QueryRow(ctx,
+		`DELETE FROM webauthn_challenges
+		 WHERE id = $1 AND ceremony = $2 AND expires_at > NOW()
+		 RETURNING user_id::text, session_data`, challengeID, ceremony).Scan(&owner, &data)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+
+	if !challengeOwnedBy(owner, userID) {
+		return nil, ErrInvalidChallenge
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// challengeOwnedBy reports whether a challenge stored for owner (nil for a
+// passwordless login) may be redeemed by userID.
+func challengeOwnedBy(owner *string, userID string) bool {
+	if owner == nil {
+		return userID == ""
+	}
+	return *owner == userID
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/oauth-app/backend/internal/models"
+)
+
+func TestBeginAssertionSplitsLoginAndMFA(t *testing.T) {
+	if err := InitWebAuthn(); err != nil {
+		t.Fatalf("InitWebAuthn: %v", err)
+	}
+
+	_, session, ceremony, err := beginAssertion(nil)
+	if err != nil {
+		t.Fatalf("passwordless: %v", err)
+	}
+	if ceremony != ceremonyLogin {
+		t.Errorf("passwordless ceremony = %q, want %q", ceremony, ceremonyLogin)
+	}
+	if session.UserVerification != protocol.VerificationRequired {
+		t.Errorf("passwordless user verification = %q, want required", session.UserVerification)
+	}
+	if len(session.UserID) != 0 || len(session.AllowedCredentialIDs) != 0 {
+		t.Error("passwordless login should not be bound to a user")
+	}
+
+	userID := "0b7c4bb4-5d3e-4f7a-9d52-2f0d1c9e8a61"
+	u := &webauthnUser{
+		user:        &models.User{ID: userID, Email: "ann@example.com"},
+		credentials: []webauthn.Credential{{ID: []byte("credential-1")}},
+	}
+	_, session, ceremony, err = beginAssertion(u)
+	if err != nil {
+		t.Fatalf("second factor: %v", err)
+	}
+	if ceremony != ceremonyMFA {
+		t.Errorf("second factor ceremony = %q, want %q", ceremony, ceremonyMFA)
+	}
+	if !bytes.Equal(session.UserID, userHandle(userID)) {
+		t.Error("second factor session is not bound to the user")
+	}
+	if len(session.AllowedCredentialIDs) != 1 || !bytes.Equal(session.AllowedCredentialIDs[0], []byte("credential-1")) {
+		t.Errorf("allowed credentials = %q, want only the user's passkey", session.AllowedCredentialIDs)
+	}
+}
+
+func TestUserHandle(t *testing.T) {
+	userID := "0b7c4bb4-5d3e-4f7a-9d52-2f0d1c9e8a61"
+	handle := userHandle(userID)
+	if len(handle) != 16 {
+		t.Fatalf("handle is %d bytes, want 16", len(handle))
+	}
+	if got := userIDFromHandle(handle); got != userID {
+		t.Errorf("userIDFromHandle = %q, want %q", got, userID)
+	}
+
+	for _, handle := range [][]byte{nil, []byte("short"), append(handle, 0)} {
+		if got := userIDFromHandle(handle); got != "" {
+			t.Errorf("userIDFromHandle(%x) = %q, want rejection", handle, got)
+		}
+	}
+}
+
+func TestChallengeOwnedBy(t *testing.T) {
+	ann, bob := "ann-id", "bob-id"
+	cases := []struct {
+		name   string
+		owner  *string
+		userID string
+		want   bool
+	}{
+		{"passwordless login", nil, "", true},
+		{"passwordless challenge used as a second factor", nil, ann, false},
+		{"second factor for the same user", &ann, ann, true},
+		{"second factor for another user", &ann, bob, false},
+		{"second factor used for a passwordless login", &ann, "", false},
+	}
+	for _, tc := range cases {
+		if got := challengeOwnedBy(tc.owner, tc.userID); got != tc.want {
+			t.Errorf("%s: challengeOwnedBy = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
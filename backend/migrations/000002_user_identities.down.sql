@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS google_id VARCHAR(255) UNIQUE;
+
+UPDATE users u SET google_id = i.subject
+FROM user_identities i
+WHERE i.user_id = u.id AND i.provider = 'google';
+
+CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id);
+
+DROP TABLE IF EXISTS user_identities;
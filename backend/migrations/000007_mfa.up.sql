@@ -0,0 +1,23 @@
+-- TOTP second factor; enabled_at stays NULL until enrollment is confirmed
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    last_used_step BIGINT DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    enabled_at TIMESTAMPTZ
+);
+
+-- One-time recovery codes (SHA-256 hashes only)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+-- Sessions created by a login that still needs its second factor
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS mfa_pending BOOLEAN DEFAULT false;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS mfa_attempts INTEGER DEFAULT 0;
@@ -0,0 +1,3 @@
+-- When the user last proved who they are in this session (login or step-up)
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS authenticated_at TIMESTAMPTZ DEFAULT NOW();
+UPDATE sessions SET authenticated_at = created_at;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS account_tombstones;
+DROP INDEX IF EXISTS idx_users_purge_after;
+ALTER TABLE users DROP COLUMN IF EXISTS purge_after;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,17 @@
+-- Accounts pending deletion stay hidden until purge_after, then are purged
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS purge_after TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_purge_after ON users(purge_after) WHERE deleted_at IS NOT NULL;
+
+-- Audit record of purged accounts (no profile data is kept)
+CREATE TABLE IF NOT EXISTS account_tombstones (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL,
+    email_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    purged_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_tombstones_user_id ON account_tombstones(user_id);
@@ -0,0 +1,10 @@
+-- Per-field policy for refreshing the profile from the login provider.
+-- A missing row means the field's default policy; edited_at is set when the
+-- user changes the field themselves.
+CREATE TABLE IF NOT EXISTS profile_sync_settings (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field VARCHAR(20) NOT NULL,
+    policy VARCHAR(20) NOT NULL,
+    edited_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, field)
+);
@@ -0,0 +1,24 @@
+-- Invitation links for invite-only sign-up (SHA-256 hashes only)
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code_hash VARCHAR(64) UNIQUE NOT NULL,
+    email VARCHAR(255) DEFAULT '',
+    max_uses INTEGER NOT NULL DEFAULT 1,
+    use_count INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_created_by ON invitations(created_by);
+
+-- One row per sign-up through an invitation
+CREATE TABLE IF NOT EXISTS invitation_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    invitation_id UUID NOT NULL REFERENCES invitations(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    redeemed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitation_redemptions_invitation_id ON invitation_redemptions(invitation_id);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS invitation_id;
+ALTER TABLE users DROP COLUMN IF EXISTS invited_by;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_suspensions;
+DROP INDEX IF EXISTS idx_users_role;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
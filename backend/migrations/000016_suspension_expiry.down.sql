@@ -0,0 +1,2 @@
+ALTER TABLE user_suspensions DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE user_suspensions DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,3 @@
+-- Temporary suspensions end on their own; bans are permanent until lifted
+ALTER TABLE user_suspensions ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'suspension';
+ALTER TABLE user_suspensions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
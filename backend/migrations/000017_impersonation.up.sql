@@ -0,0 +1,2 @@
+-- Sessions an administrator opened as another user ("log in as")
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS impersonator_id UUID REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,19 @@
+-- Device authorization requests (RFC 8628). The device polls with the
+-- device code while the user approves the user code in the browser.
+CREATE TABLE IF NOT EXISTS oauth_device_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    device_code_hash VARCHAR(64) UNIQUE NOT NULL,
+    user_code VARCHAR(8) UNIQUE NOT NULL,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    -- pending, approved or denied
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    authenticated_at TIMESTAMPTZ,
+    -- Minimum seconds between polls, raised when the device polls too fast
+    interval_seconds INT NOT NULL DEFAULT 5,
+    last_polled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_scim_external_id;
+ALTER TABLE users DROP COLUMN IF EXISTS scim_external_id;
+ALTER TABLE users DROP COLUMN IF EXISTS scim_tenant_id;
+DROP TABLE IF EXISTS scim_tenants;
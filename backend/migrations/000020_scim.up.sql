@@ -0,0 +1,20 @@
+-- Directories that provision users through SCIM 2.0, each with its own
+-- bearer token
+CREATE TABLE IF NOT EXISTS scim_tenants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    -- First characters of the token, to tell tokens apart in the admin UI
+    token_prefix VARCHAR(20) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Users provisioned by a tenant, which alone may manage them
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_tenant_id UUID REFERENCES scim_tenants(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_scim_external_id ON users(scim_tenant_id, scim_external_id)
+    WHERE scim_external_id IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Failed code checks per user, across sessions; too many lock code checks
+-- for a while
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_locked_until TIMESTAMPTZ;
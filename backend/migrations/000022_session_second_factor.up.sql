@@ -0,0 +1,2 @@
+-- When the session last presented a second factor (login or step-up)
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS second_factor_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE activity_logs DROP COLUMN IF EXISTS impersonator_id;
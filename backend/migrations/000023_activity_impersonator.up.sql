@@ -0,0 +1,2 @@
+-- The administrator who acted while signed in as the user, if any
+ALTER TABLE activity_logs ADD COLUMN IF NOT EXISTS impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL;
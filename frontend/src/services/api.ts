@@ -10,6 +10,38 @@ const api = axios.create({
     },
 })
 
+// The backend revokes the session when a refresh token is used twice, so
+// parallel requests must not each refresh an expired access token. Requests
+// wait for one shared refresh shortly before the 15-minute access token runs
+// out (and on page load, when its age is unknown). The lock keeps tabs from
+// refreshing at the same time.
+const REFRESH_AFTER_MS = 14 * 60 * 1000
+
+let refreshedAt = 0
+let refreshing: Promise<void> | null = null
+
+const refreshSession = () => {
+    if (!refreshing) {
+        const refresh = () => axios.post(`${API_URL}/auth/refresh`, null, { withCredentials: true })
+        refreshing = (navigator.locks ? navigator.locks.request('auth-refresh', refresh) : refresh())
+            .then(() => undefined)
+            // Signed out: the request itself gets the 401
+            .catch(() => undefined)
+            .finally(() => {
+                refreshedAt = Date.now()
+                refreshing = null
+            })
+    }
+    return refreshing
+}
+
+api.interceptors.request.use(async (config) => {
+    if (Date.now() - refreshedAt > REFRESH_AFTER_MS) {
+        await refreshSession()
+    }
+    return config
+})
+
 // Auth
 export const getCurrentUser = () => api.get('/auth/me')
 export const logout = () => api.post('/auth/logout')